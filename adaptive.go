@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// SizesRefinedMsg carries the sizes of the directories still being refined
+// after another, deeper pass of the adaptive scan
+type SizesRefinedMsg struct {
+	Root   *scan.DirEntry
+	Depth  int
+	Totals map[string]scan.Totals
+	// Complete is set for the directories whose totals are final
+	Complete map[string]bool
+}
+
+// refineSizes sizes the children of root that are still refining, descending
+// depth levels into each, up to maxDepth
+func refineSizes(ctx context.Context, root *scan.DirEntry, depth, maxDepth int) tea.Cmd {
+	var paths []string
+	for _, child := range root.Children {
+		if child.Refining {
+			paths = append(paths, child.Path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil
+	}
+
+	return func() (refined tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				// The sizes stay marked as refining
+				scanPanic(r)
+				refined = nil
+			}
+		}()
+
+		msg := SizesRefinedMsg{
+			Root:     root,
+			Depth:    depth,
+			Totals:   make(map[string]scan.Totals),
+			Complete: make(map[string]bool),
+		}
+		for _, path := range paths {
+			totals, final := scanner.SizeLevels(ctx, path, depth, maxDepth)
+			if ctx.Err() != nil {
+				// Navigated away, the partial sizes are of no use
+				return nil
+			}
+			msg.Totals[path] = totals
+			msg.Complete[path] = final
+		}
+		return msg
+	}
+}
+
+// applyRefinedSizes updates the refined children and the totals depending on
+// them, then starts the next pass with twice the depth while any are left
+func (m *Model) applyRefinedSizes(msg SizesRefinedMsg) tea.Cmd {
+	if msg.Root != m.RootDir {
+		return nil
+	}
+
+	root := m.RootDir
+	for _, child := range root.Children {
+		totals, ok := msg.Totals[child.Path]
+		if !ok {
+			continue
+		}
+		root.Size += totals.Size - child.Size
+		root.FileCount += totals.Files - child.FileCount
+		root.DirCount += totals.Dirs - child.DirCount
+		root.Apparent += totals.Apparent - child.Apparent
+		root.Allocated += totals.Allocated - child.Allocated
+		child.Size = totals.Size
+		child.FileCount = totals.Files
+		child.DirCount = totals.Dirs
+		child.Apparent = totals.Apparent
+		child.Allocated = totals.Allocated
+		root.Unreadable += totals.Unreadable - child.Unreadable
+		child.Unreadable = totals.Unreadable
+		child.ReadError = totals.ReadError
+		child.Refining = !msg.Complete[child.Path]
+		child.Truncated = totals.Truncated
+	}
+	for _, child := range root.Children {
+		child.Percent = 0
+		if root.Size > 0 {
+			child.Percent = float64(child.Size) / float64(root.Size) * 100
+		}
+	}
+
+	selected := m.selectedEntry()
+	m.rebuildVisibleDirs()
+	if selected != nil && m.selectPath(selected.Path) {
+		m.ensureCursorVisible()
+	}
+	return refineSizes(m.scanCtx, root, msg.Depth*2, m.MaxDepth)
+}
+
+// refineCmd starts refining the current directory after an adaptive scan
+func (m Model) refineCmd() tea.Cmd {
+	if !m.AdaptiveScan || m.RootDir == nil || m.scanCtx == nil {
+		return nil
+	}
+	return refineSizes(m.scanCtx, m.RootDir, 1, m.MaxDepth)
+}
+
+// refining reports whether any child of the current directory is still being refined
+func (m Model) refining() bool {
+	if m.RootDir == nil {
+		return false
+	}
+	for _, child := range m.RootDir.Children {
+		if child.Refining {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// runAlert sizes root and reports when it is over limit bytes, running command
+// (through the shell, with USAGE_ALERT_PATH and USAGE_ALERT_SIZE set) if one is
+// given. Once, it returns exit code 1 on a breach and 0 otherwise, staying
+// silent when under the limit so it suits cron. With watch it rescans every
+// interval, reports each time the limit is crossed in either direction, and
+// never returns.
+func runAlert(w io.Writer, root string, limit int64, command string, watch bool, interval time.Duration) int {
+	over := false
+	for {
+		scanner.Invalidate(root)
+		size := scanner.FullDirSize(context.Background(), root, 0, nil).Size
+
+		switch {
+		case size > limit && !over:
+			fmt.Fprintf(w, "%s  %s is %s, over the limit of %s\n", time.Now().Format(time.RFC3339),
+				root, humanize.Bytes(uint64(size)), humanize.Bytes(uint64(limit)))
+			runAlertCommand(command, root, size)
+		case size <= limit && over:
+			fmt.Fprintf(w, "%s  %s is %s, back under the limit of %s\n", time.Now().Format(time.RFC3339),
+				root, humanize.Bytes(uint64(size)), humanize.Bytes(uint64(limit)))
+		}
+		over = size > limit
+
+		if !watch {
+			if over {
+				return 1
+			}
+			return 0
+		}
+		time.Sleep(interval)
+	}
+}
+
+// runAlertCommand runs the user's command for a breach, if any, passing its output through
+func runAlertCommand(command, root string, size int64) {
+	if command == "" {
+		return
+	}
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "USAGE_ALERT_PATH="+root, "USAGE_ALERT_SIZE="+strconv.FormatInt(size, 10))
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Alert command failed: %v\n", err)
+	}
+}
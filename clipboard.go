@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ClipboardMsg is sent when text has been copied to the clipboard
+type ClipboardMsg struct {
+	Text  string
+	Error error
+}
+
+// clipboardCommands are tried in order to set the system clipboard; a
+// command is only used when its display variable (if any) is set
+var clipboardCommands = []struct {
+	display string
+	args    []string
+}{
+	{"", []string{"pbcopy"}},
+	{"WAYLAND_DISPLAY", []string{"wl-copy"}},
+	{"DISPLAY", []string{"xclip", "-selection", "clipboard"}},
+	{"DISPLAY", []string{"xsel", "--clipboard", "--input"}},
+	{"", []string{"clip.exe"}},
+}
+
+// copyToClipboard puts text on the clipboard with the first available
+// clipboard program. Without one it falls back to the OSC 52 escape
+// sequence, which most terminals honor, also over SSH, as long as there is
+// a terminal to send it to.
+func copyToClipboard(text string) error {
+	for _, c := range clipboardCommands {
+		if c.display != "" && os.Getenv(c.display) == "" {
+			continue
+		}
+		path, err := exec.LookPath(c.args[0])
+		if err != nil {
+			continue
+		}
+		cmd := exec.Command(path, c.args[1:]...)
+		cmd.Stdin = strings.NewReader(text)
+		return cmd.Run()
+	}
+
+	if info, err := os.Stderr.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return errors.New("no clipboard program found (pbcopy, wl-copy, xclip, xsel or clip.exe) and no terminal for OSC 52")
+	}
+	seq := osc52.New(text)
+	if os.Getenv("TMUX") != "" {
+		seq = seq.Tmux()
+	} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+		seq = seq.Screen()
+	}
+	_, err := seq.WriteTo(os.Stderr)
+	return err
+}
+
+// copyCmd copies text to the clipboard in the background
+func copyCmd(text string) tea.Cmd {
+	return func() tea.Msg {
+		return ClipboardMsg{text, copyToClipboard(text)}
+	}
+}
+
+// sizeText describes a size for pasting, e.g. "1.2 GB (1234567890 bytes)"
+func sizeText(size int64) string {
+	return fmt.Sprintf("%s (%d bytes)", strings.TrimSpace(formatSize(size, true)), size)
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// CommandDoneMsg is sent when a user command started with "!" exits.
+// Modifies is set for commands that may have changed files.
+type CommandDoneMsg struct {
+	Command  string
+	Error    error
+	Modifies bool
+}
+
+// destructiveCommands are programs that make a command template ask for confirmation
+var destructiveCommands = map[string]bool{
+	"rm": true, "rmdir": true, "unlink": true, "shred": true, "mv": true,
+	"dd": true, "truncate": true, "chmod": true, "chown": true, "find": true,
+}
+
+// shellQuote quotes s for safe use as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// expandCommand substitutes every {} in template with the quoted path. When
+// the template has no placeholder the path is appended as the last argument.
+func expandCommand(template, path string) string {
+	if !strings.Contains(template, "{}") {
+		return template + " " + shellQuote(path)
+	}
+	return strings.ReplaceAll(template, "{}", shellQuote(path))
+}
+
+// isDestructiveCommand reports whether a command template runs a program that
+// modifies or removes files, or redirects output into one
+func isDestructiveCommand(template string) bool {
+	if strings.Contains(template, ">") {
+		return true
+	}
+	words := strings.FieldsFunc(template, func(r rune) bool {
+		return r == ' ' || r == '\t' || r == ';' || r == '|' || r == '&' || r == '(' || r == ')'
+	})
+	for _, word := range words {
+		if destructiveCommands[filepath.Base(word)] {
+			return true
+		}
+	}
+	return false
+}
+
+// runCommand suspends the UI and runs the command through the shell, waiting
+// for enter afterwards so its output can be read before the UI returns
+func runCommand(command string, modifies bool) tea.Cmd {
+	return tea.ExecProcess(exec.Command("sh", "-c", commandScript(command)), func(err error) tea.Msg {
+		return CommandDoneMsg{command, err, modifies}
+	})
+}
+
+// commandScript wraps command for sh -c so it exits with the command's
+// status after the enter prompt. The command gets lines of its own, so a
+// trailing # comment can't swallow the rest of the script.
+func commandScript(command string) string {
+	return "(\n" + command + "\n)\ns=$?; printf '\\n[press enter to return] '; read _; exit $s"
+}
+
+// promptCommand asks for a command template to run on the entry's path
+func (m *Model) promptCommand(entry *scan.DirEntry) {
+	m.Input = &InputPrompt{
+		Prompt: "! command ({} = path): ",
+		OnSubmit: func(m *Model, template string) tea.Cmd {
+			if strings.TrimSpace(template) == "" {
+				return nil
+			}
+			command := expandCommand(template, entry.Path)
+			if isDestructiveCommand(template) {
+				m.Confirm = &Confirmation{
+					Prompt: fmt.Sprintf("Run %s? [y/N]", command),
+					OnYes:  runCommand(command, true),
+				}
+				return nil
+			}
+			return runCommand(command, false)
+		},
+	}
+}
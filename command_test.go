@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCommandScriptKeepsExitStatus(t *testing.T) {
+	cases := []struct {
+		command string
+		status  int
+	}{
+		{"true", 0},
+		{"exit 3", 3},
+		{"false # a trailing comment", 1},
+		{"ls /nonexistent-dir 2>/dev/null; exit 4 #", 4},
+	}
+	for _, c := range cases {
+		cmd := exec.Command("sh", "-c", commandScript(c.command))
+		// Enter is pressed right away
+		cmd.Stdin = strings.NewReader("\n")
+		err := cmd.Run()
+		status := 0
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			status = exitErr.ExitCode()
+		} else if err != nil {
+			t.Fatalf("%q: %v", c.command, err)
+		}
+		if status != c.status {
+			t.Errorf("%q exited with %d, want %d", c.command, status, c.status)
+		}
+	}
+}
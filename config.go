@@ -0,0 +1,356 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"usage/scan"
+)
+
+// Config holds the settings that can be stored in the config file. Values are
+// applied in order: built-in defaults, config file, environment, flags.
+type Config struct {
+	ShowFiles   bool   `json:"show_files"`
+	NoAltScreen bool   `json:"no_alt_screen"`
+	TopDirs     int    `json:"top_dirs"`
+	Label       string `json:"label"`
+	// Palette names the theme, built in or from Themes
+	Palette    string `json:"palette"`
+	SizeFormat string `json:"size_format"`
+	// Themes adds palettes, or replaces built-in ones, by name
+	Themes map[string]Palette `json:"themes"`
+	// PercentDecimals is the precision of the percent column, 0 to 2
+	PercentDecimals int `json:"percent_decimals"`
+	// HiddenSummary notes the number and size of the hidden entries left
+	// out, which sizes every hidden directory in full
+	HiddenSummary bool   `json:"hidden_summary"`
+	ScanStrategy  string `json:"scan_strategy"`
+	MaxChildren   int    `json:"max_children"`
+	// MaxDepth limits the directory levels summed for each listed directory, 0 for all
+	MaxDepth      int    `json:"max_depth"`
+	HomeRelative  bool   `json:"home_relative"`
+	EnterAction   string `json:"enter_action"`
+	SkipPseudoFS  bool   `json:"skip_pseudo_fs"`
+	OneFileSystem bool   `json:"one_file_system"`
+	// MinSize such as "10M" leaves smaller entries out of the listing
+	MinSize string `json:"min_size"`
+	// ShowHidden includes entries whose names start with a dot
+	ShowHidden bool `json:"show_hidden"`
+	// FollowSymlinks sizes the targets of symbolic links instead of the links
+	FollowSymlinks bool `json:"follow_symlinks"`
+	// Workers caps the goroutines summing directory sizes, 0 for one per CPU
+	Workers int `json:"workers"`
+	// SizeProvider picks how file sizes are measured, see scan.SizeProviders
+	SizeProvider string `json:"size_provider"`
+	// ErrorMode is "quiet", "warn" or "strict", see scan.Scanner.ErrorMode
+	ErrorMode string `json:"error_mode"`
+	// Ignore lists absolute paths left out of every scan; I adds to it from the UI
+	Ignore []string `json:"ignore"`
+	// Locale such as "de_DE" picks the digit grouping of counts; empty follows LC_ALL/LC_NUMERIC/LANG
+	Locale string `json:"locale"`
+	// FollowMounts lists mount points entered even with OneFileSystem set
+	FollowMounts []string `json:"follow_mounts"`
+	// Trash moves deleted entries to the trash instead of removing them, so u
+	// can put them back
+	Trash bool `json:"trash"`
+	// Exclude lists glob patterns (e.g. "node_modules") of names never scanned,
+	// or of absolute paths when they contain a separator
+	Exclude []string `json:"exclude"`
+}
+
+// defaultConfig returns the settings used when nothing else is configured
+func defaultConfig() Config {
+	return Config{
+		ShowFiles:       true,
+		TopDirs:         20,
+		Palette:         "default",
+		SizeFormat:      "aligned",
+		PercentDecimals: 1,
+		ScanStrategy:    "recursive",
+		MaxChildren:     500,
+		EnterAction:     "navigate",
+		SkipPseudoFS:    true,
+		SizeProvider:    "apparent",
+		ErrorMode:       "warn",
+	}
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/usage/config.json, or the
+// platform's equivalent user config directory
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "usage", "config.json")
+}
+
+// loadConfig reads the config file at path over the defaults. An empty path
+// means the default location, which may be missing; an explicit path must exist.
+func loadConfig(path string) (Config, error) {
+	cfg := defaultConfig()
+
+	explicit := path != ""
+	if !explicit {
+		path = defaultConfigPath()
+		if path == "" {
+			return cfg, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !explicit && errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&cfg); err != nil {
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return cfg, fmt.Errorf("%s: %v (at byte %d)", path, err, syntaxErr.Offset)
+		}
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// palette looks name up in Themes, then in the built-in palettes
+func (c Config) palette(name string) (Palette, bool) {
+	if palette, ok := c.Themes[name]; ok {
+		return palette, true
+	}
+	palette, ok := palettes[name]
+	return palette, ok
+}
+
+// applyEnv overrides the settings with the USAGE_* environment variables that are set
+func (c *Config) applyEnv() {
+	if value, ok := os.LookupEnv("USAGE_SHOW_FILES"); ok {
+		c.ShowFiles = value != "false"
+	}
+	c.NoAltScreen = envBool("USAGE_NO_ALT_SCREEN", c.NoAltScreen)
+	c.HiddenSummary = envBool("USAGE_HIDDEN_SUMMARY", c.HiddenSummary)
+	c.HomeRelative = envBool("USAGE_HOME_RELATIVE", c.HomeRelative)
+	c.SkipPseudoFS = envBool("USAGE_SKIP_PSEUDO_FS", c.SkipPseudoFS)
+	c.OneFileSystem = envBool("USAGE_ONE_FILE_SYSTEM", c.OneFileSystem)
+	c.FollowSymlinks = envBool("USAGE_FOLLOW_SYMLINKS", c.FollowSymlinks)
+	c.ShowHidden = envBool("USAGE_SHOW_HIDDEN", c.ShowHidden)
+	c.Trash = envBool("USAGE_TRASH", c.Trash)
+	// Added to the configured patterns, like the project's; malformed ones are dropped
+	for _, pattern := range strings.Split(os.Getenv("USAGE_EXCLUDE"), ",") {
+		pattern = strings.TrimSpace(pattern)
+		if _, err := filepath.Match(pattern, ""); pattern != "" && err == nil {
+			c.Exclude = append(c.Exclude, pattern)
+		}
+	}
+	if value := os.Getenv("USAGE_LOCALE"); value != "" {
+		c.Locale = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("USAGE_PERCENT_DECIMALS")); err == nil && value >= 0 && value <= maxPercentDecimals {
+		c.PercentDecimals = value
+	}
+	// 0 is allowed here and means no limit
+	if value, err := strconv.Atoi(os.Getenv("USAGE_MAX_DEPTH")); err == nil && value >= 0 {
+		c.MaxDepth = value
+	}
+	// 0 is allowed here and lists every child
+	if value, err := strconv.Atoi(os.Getenv("USAGE_MAX_CHILDREN")); err == nil && value >= 0 {
+		c.MaxChildren = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("USAGE_WORKERS")); err == nil && value >= 0 {
+		c.Workers = value
+	}
+	if value := os.Getenv("USAGE_MIN_SIZE"); value != "" {
+		if _, err := parseMinSize(value); err == nil {
+			c.MinSize = value
+		}
+	}
+
+	// Unknown values fall back to the configured ones rather than failing
+	if _, ok := c.palette(os.Getenv("USAGE_PALETTE")); ok {
+		c.Palette = os.Getenv("USAGE_PALETTE")
+	}
+	if _, ok := c.palette(os.Getenv("USAGE_THEME")); ok {
+		c.Palette = os.Getenv("USAGE_THEME")
+	}
+	if _, ok := scan.SizeProviders[os.Getenv("USAGE_SIZE_PROVIDER")]; ok {
+		c.SizeProvider = os.Getenv("USAGE_SIZE_PROVIDER")
+	}
+	if value, err := strconv.ParseBool(os.Getenv("USAGE_APPARENT_SIZE")); err == nil {
+		c.SizeProvider = "allocated"
+		if value {
+			c.SizeProvider = "apparent"
+		}
+	}
+	if value := os.Getenv("USAGE_SIZE_FORMAT"); value == "aligned" || value == "plain" {
+		c.SizeFormat = value
+	}
+	if value := os.Getenv("USAGE_SCAN_STRATEGY"); value == "recursive" || value == "walk" || value == "adaptive" {
+		c.ScanStrategy = value
+	}
+	if value := os.Getenv("USAGE_ERROR_MODE"); value == "quiet" || value == "warn" || value == "strict" {
+		c.ErrorMode = value
+	}
+	if value := os.Getenv("USAGE_ENTER_ACTION"); value == "navigate" || value == "expand" {
+		c.EnterAction = value
+	}
+}
+
+// validate reports the first setting with an unsupported value
+func (c Config) validate() error {
+	if _, ok := c.palette(c.Palette); !ok {
+		return fmt.Errorf("unknown palette %q", c.Palette)
+	}
+	if _, ok := scan.SizeProviders[c.SizeProvider]; !ok {
+		return fmt.Errorf("unknown size_provider %q", c.SizeProvider)
+	}
+	if c.SizeFormat != "aligned" && c.SizeFormat != "plain" {
+		return fmt.Errorf("size_format must be \"aligned\" or \"plain\", got %q", c.SizeFormat)
+	}
+	if c.ScanStrategy != "recursive" && c.ScanStrategy != "walk" && c.ScanStrategy != "adaptive" {
+		return fmt.Errorf("scan_strategy must be \"recursive\", \"walk\" or \"adaptive\", got %q", c.ScanStrategy)
+	}
+	if c.EnterAction != "navigate" && c.EnterAction != "expand" {
+		return fmt.Errorf("enter_action must be \"navigate\" or \"expand\", got %q", c.EnterAction)
+	}
+	if c.PercentDecimals < 0 || c.PercentDecimals > maxPercentDecimals {
+		return fmt.Errorf("percent_decimals must be between 0 and %d, got %d", maxPercentDecimals, c.PercentDecimals)
+	}
+	if c.ErrorMode != "quiet" && c.ErrorMode != "warn" && c.ErrorMode != "strict" {
+		return fmt.Errorf("error_mode must be \"quiet\", \"warn\" or \"strict\", got %q", c.ErrorMode)
+	}
+	if c.TopDirs < 1 {
+		return fmt.Errorf("top_dirs must be at least 1, got %d", c.TopDirs)
+	}
+	if c.MaxDepth < 0 {
+		return fmt.Errorf("max_depth must not be negative, got %d", c.MaxDepth)
+	}
+	if c.MaxChildren < 0 {
+		return fmt.Errorf("max_children must not be negative, got %d", c.MaxChildren)
+	}
+	if c.Workers < 0 {
+		return fmt.Errorf("workers must not be negative, got %d", c.Workers)
+	}
+	if _, err := parseMinSize(c.MinSize); err != nil {
+		return fmt.Errorf("min_size must be a size such as \"10M\", got %q", c.MinSize)
+	}
+	for _, path := range c.Ignore {
+		if !filepath.IsAbs(path) {
+			return fmt.Errorf("ignore paths must be absolute, got %q", path)
+		}
+	}
+	for _, pattern := range c.Exclude {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return fmt.Errorf("bad exclude pattern %q: %v", pattern, err)
+		}
+	}
+	return nil
+}
+
+// updateIgnoreList adds path to, or removes it from, the ignore list in the
+// config file at configPath. Only the ignore value is rewritten, so the other
+// settings keep their order, formatting and values as written.
+func updateIgnoreList(configPath, path string, ignored bool) error {
+	settings := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(configPath)
+	if err == nil {
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("%s: %v", configPath, err)
+		}
+	} else if errors.Is(err, fs.ErrNotExist) {
+		data = []byte("{}")
+	} else {
+		return err
+	}
+
+	var paths []string
+	if raw, ok := settings["ignore"]; ok {
+		if err := json.Unmarshal(raw, &paths); err != nil {
+			return fmt.Errorf("%s: ignore: %v", configPath, err)
+		}
+	}
+	kept := make([]string, 0, len(paths)+1)
+	for _, p := range paths {
+		if p != path {
+			kept = append(kept, p)
+		}
+	}
+	if ignored {
+		kept = append(kept, path)
+	}
+
+	var updated []byte
+	if start, end, ok := jsonValueSpan(data, "ignore"); ok {
+		// Indented like the line the value starts on
+		line := data[bytes.LastIndexByte(data[:start], '\n')+1 : start]
+		indent := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		value, err := json.MarshalIndent(kept, string(indent), "  ")
+		if err != nil {
+			return err
+		}
+		updated = append(append(append(updated, data[:start]...), value...), data[end:]...)
+	} else {
+		value, err := json.MarshalIndent(kept, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		// Added as the last setting
+		closing := bytes.LastIndexByte(data, '}')
+		before := bytes.TrimRight(data[:closing], " \t\r\n")
+		updated = append(updated, before...)
+		if len(settings) > 0 {
+			updated = append(updated, ',')
+		}
+		updated = append(updated, "\n  \"ignore\": "...)
+		updated = append(updated, value...)
+		updated = append(updated, '\n')
+		updated = append(updated, data[closing:]...)
+	}
+	if !bytes.HasSuffix(updated, []byte("\n")) {
+		updated = append(updated, '\n')
+	}
+
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, updated, 0o644)
+}
+
+// jsonValueSpan returns where the value of key starts and ends in data, a
+// JSON object, if key is one of its top-level members. The last one counts
+// when the key is repeated, as it does for json.Unmarshal.
+func jsonValueSpan(data []byte, key string) (start, end int, ok bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return 0, 0, false
+	}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return 0, 0, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, false
+		}
+		if tok == key {
+			end = int(dec.InputOffset())
+			start, ok = end-len(value), true
+		}
+	}
+	return start, end, ok
+}
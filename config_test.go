@@ -0,0 +1,120 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUpdateIgnoreListKeepsOtherSettings(t *testing.T) {
+	cases := []struct {
+		name    string
+		before  string
+		path    string
+		ignored bool
+		after   string
+	}{
+		{
+			name: "add to the list",
+			before: `{
+  "top_dirs": 30,
+  "ignore": ["/data/old"],
+  "label":    "NAS"
+}
+`,
+			path:    "/data/cache",
+			ignored: true,
+			after: `{
+  "top_dirs": 30,
+  "ignore": [
+    "/data/old",
+    "/data/cache"
+  ],
+  "label":    "NAS"
+}
+`,
+		},
+		{
+			name:   "remove the last one",
+			before: `{"label": "NAS", "ignore": ["/data/old"], "palette": "dark"}`,
+			path:   "/data/old",
+			after:  `{"label": "NAS", "ignore": [], "palette": "dark"}` + "\n",
+		},
+		{
+			name: "add the setting",
+			before: `{
+  "top_dirs": 30,
+  "label": "NAS"
+}
+`,
+			path:    "/data/cache",
+			ignored: true,
+			after: `{
+  "top_dirs": 30,
+  "label": "NAS",
+  "ignore": [
+    "/data/cache"
+  ]
+}
+`,
+		},
+		{
+			name:    "create the file",
+			path:    "/data/cache",
+			ignored: true,
+			after: `{
+  "ignore": [
+    "/data/cache"
+  ]
+}
+`,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.json")
+			if c.before != "" {
+				if err := os.WriteFile(configPath, []byte(c.before), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := updateIgnoreList(configPath, c.path, c.ignored); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != c.after {
+				t.Errorf("config is now\n%s\nwant\n%s", data, c.after)
+			}
+		})
+	}
+}
+
+func TestThemesStayWithTheirConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	data := `{"palette": "solar", "themes": {"solar": {"dir": "136"}, "dark": {"dir": "33"}}}`
+	if err := os.WriteFile(configPath, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := loadConfig(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if palette, ok := cfg.palette("solar"); !ok || palette.Dir != "136" {
+		t.Errorf("solar = %+v, %v, want the configured theme", palette, ok)
+	}
+	if palette, _ := cfg.palette("dark"); palette.Dir != "33" {
+		t.Errorf("dark = %+v, want the configured theme over the built-in one", palette)
+	}
+
+	// Another config sees only the built-in palettes
+	other := defaultConfig()
+	if _, ok := other.palette("solar"); ok {
+		t.Error("solar leaked into another config")
+	}
+	if palette, _ := other.palette("dark"); palette != darkPalette {
+		t.Errorf("dark = %+v, want the built-in palette", palette)
+	}
+}
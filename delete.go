@@ -0,0 +1,152 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+
+	"usage/scan"
+)
+
+// DeleteMsg is sent when entries have been deleted from disk, or moved to
+// the trash in trash mode
+type DeleteMsg struct {
+	Paths []string
+	// Trashed are the entries of Paths moved to the trash, in the same order
+	Trashed []trashedEntry
+	Error   error
+}
+
+// deleteCmd removes paths, directories with everything inside them, and stops at the first failure
+func deleteCmd(paths []string) tea.Cmd {
+	return func() tea.Msg {
+		for i, path := range paths {
+			remove := os.Remove
+			if info, err := os.Lstat(path); err == nil && info.IsDir() {
+				remove = os.RemoveAll
+			}
+			if err := remove(path); err != nil {
+				return DeleteMsg{paths[:i], nil, err}
+			}
+		}
+		return DeleteMsg{paths, nil, nil}
+	}
+}
+
+// confirmDelete asks before deleting the selected entry
+func (m *Model) confirmDelete() {
+	entry := m.selectedEntry()
+	if entry == nil || isParentEntry(entry) || entry.Summary || m.ShowTopDirs {
+		return
+	}
+	if entry == m.RootDir {
+		// Nothing would be left to show, and the re-scan would fail
+		m.Status = "Go back to the file's directory to delete it"
+		return
+	}
+	what := "file"
+	if entry.IsDir {
+		what = "directory"
+	}
+	prompt := fmt.Sprintf("Delete %s %s (%s)? [y/N]", what, entry.Path, humanize.Bytes(uint64(entry.Size)))
+	if m.Trash {
+		prompt = fmt.Sprintf("Move %s %s (%s) to the trash? [y/N]", what, entry.Path, humanize.Bytes(uint64(entry.Size)))
+	}
+	m.Confirm = &Confirmation{
+		Prompt: prompt,
+		OnYes:  m.removeCmd([]string{entry.Path}),
+	}
+}
+
+// applyDelete takes the deleted entries out of the list right away, then
+// re-scans the current directory in the background to catch anything else
+// that changed, highlighting it. The re-scan is compared with the listing
+// from before the deletion, so the deleted entries are counted as removed.
+func (m *Model) applyDelete(msg DeleteMsg) tea.Cmd {
+	if len(msg.Paths) > 0 && m.flashBase == nil {
+		m.flashBase = m.VisibleDirs
+	}
+	for _, path := range msg.Paths {
+		scanner.Invalidate(path)
+		delete(m.Plan, path)
+		m.removeEntry(path)
+	}
+	m.trashed = append(m.trashed, msg.Trashed...)
+	if msg.Error != nil {
+		m.Status = fmt.Sprintf("Delete failed: %v", msg.Error)
+	} else if len(msg.Trashed) == 1 {
+		m.Status = fmt.Sprintf("Moved %s to the trash, u to undo", msg.Paths[0])
+	} else if len(msg.Trashed) > 1 {
+		m.Status = fmt.Sprintf("Moved %d entries to the trash, u to undo", len(msg.Paths))
+	} else if len(msg.Paths) == 1 {
+		m.Status = fmt.Sprintf("Deleted %s", msg.Paths[0])
+	} else {
+		m.Status = fmt.Sprintf("Deleted %d entries", len(msg.Paths))
+	}
+	if len(msg.Paths) == 0 {
+		return nil
+	}
+
+	cursor, scroll := m.CursorPos, m.ScrollPos
+	m.updateVisibleDirs()
+	// The cursor moves to the next entry, or the last one if the deleted entry was last
+	m.CursorPos, m.ScrollPos = cursor, scroll
+	m.ensureCursorVisible()
+
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
+	}
+}
+
+// removeEntry drops the listed entry at path from its parent, subtracts it
+// from the totals above it up to RootDir and recomputes the percentages there
+func (m *Model) removeEntry(path string) {
+	var entry *scan.DirEntry
+	for _, visible := range m.VisibleDirs {
+		if visible.Path == path && !isParentEntry(visible) && !visible.Summary {
+			entry = visible
+			break
+		}
+	}
+	if entry == nil || entry.ParentDir == nil {
+		return
+	}
+
+	parent := entry.ParentDir
+	for i, child := range parent.Children {
+		if child == entry {
+			parent.Children = append(parent.Children[:i:i], parent.Children[i+1:]...)
+			break
+		}
+	}
+	parent.ItemCount--
+	if entry.IsDir {
+		parent.ChildDirs--
+	} else {
+		parent.ChildFiles--
+		parent.OwnSize -= entry.Size
+	}
+	for dir := parent; dir != nil; dir = dir.ParentDir {
+		dir.Size -= entry.Size
+		dir.FileCount -= entry.FileCount
+		dir.DirCount -= entry.DirCount
+		if entry.IsDir {
+			dir.DirCount--
+		}
+		dir.Apparent -= entry.Apparent
+		dir.Allocated -= entry.Allocated
+		dir.Unreadable -= entry.Unreadable
+		for _, child := range dir.Children {
+			child.Percent = 0
+			if dir.Size > 0 {
+				child.Percent = float64(child.Size) / float64(dir.Size) * 100
+			}
+		}
+		if dir == m.RootDir {
+			break
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestDeleteSkipsRowsThatAreNotEntries(t *testing.T) {
+	d := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}
+	cases := []struct {
+		name   string
+		setup  func(m *Model)
+		delete bool
+	}{
+		{"directory", func(m *Model) { m.CursorPos = 1 }, true},
+		{"parent", func(m *Model) { m.CursorPos = 0 }, false},
+		{"summary", func(m *Model) {
+			// Entries beyond the first two are rolled into one row
+			m.MaxChildren = 2
+			m.updateVisibleDirs()
+			m.CursorPos = len(m.VisibleDirs) - 1
+		}, false},
+		{"single file", func(m *Model) {
+			m.RootDir = m.RootDir.Children[5]
+			m.updateVisibleDirs()
+		}, false},
+		{"largest directories", func(m *Model) {
+			m.ShowTopDirs = true
+			m.VisibleDirs = m.RootDir.Children[:3]
+			m.CursorPos = 1
+		}, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m := newTestModel(5, 5)
+			c.setup(&m)
+			tm, _ := m.Update(d)
+			if asked := tm.(Model).Confirm != nil; asked != c.delete {
+				t.Errorf("asked to delete %s: %v, want %v", m.VisibleDirs[m.CursorPos].Name, asked, c.delete)
+			}
+		})
+	}
+}
+
+func TestRefreshAfterDeleteCountsRemoved(t *testing.T) {
+	m := newTestModel(5, 5)
+	deleted := m.RootDir.Children[0].Path
+	m.applyDelete(DeleteMsg{Paths: []string{deleted}})
+	if m.Status != "Deleted "+deleted {
+		t.Errorf("status after deleting is %q", m.Status)
+	}
+
+	// The re-scan finds the directory as applyDelete left it
+	m.applyRefresh(LoadingCompleteMsg{Dir: m.RootDir})
+	if want := "1 removed, 0 changed"; m.Status != want {
+		t.Errorf("status after the re-scan is %q, want %q", m.Status, want)
+	}
+	if m.flashBase != nil {
+		t.Error("the listing from before the deletion is kept after the re-scan")
+	}
+}
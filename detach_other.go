@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os/exec"
+
+// detach does nothing where child processes outlive their parent anyway
+func detach(cmd *exec.Cmd) {}
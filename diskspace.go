@@ -0,0 +1,18 @@
+package main
+
+// DiskSpace is the size and usage of the filesystem holding a path
+type DiskSpace struct {
+	Total int64
+	Used  int64
+	// Free is what unprivileged users can still write
+	Free int64
+}
+
+// loadDiskSpace returns the space of the filesystem holding path, or nil
+// where that can't be found out
+func loadDiskSpace(path string) *DiskSpace {
+	if space, ok := getDiskSpace(path); ok {
+		return &space
+	}
+	return nil
+}
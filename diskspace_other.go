@@ -0,0 +1,8 @@
+//go:build !linux && !darwin && !freebsd
+
+package main
+
+// getDiskSpace is only implemented where statfs is available
+func getDiskSpace(path string) (DiskSpace, bool) {
+	return DiskSpace{}, false
+}
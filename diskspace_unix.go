@@ -0,0 +1,19 @@
+//go:build linux || darwin || freebsd
+
+package main
+
+import "syscall"
+
+// getDiskSpace asks statfs about the filesystem holding path
+func getDiskSpace(path string) (DiskSpace, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil || st.Blocks == 0 {
+		return DiskSpace{}, false
+	}
+	bsize := int64(st.Bsize)
+	return DiskSpace{
+		Total: int64(st.Blocks) * bsize,
+		Used:  (int64(st.Blocks) - int64(st.Bfree)) * bsize,
+		Free:  int64(st.Bavail) * bsize,
+	}, true
+}
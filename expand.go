@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// ExpandMsg is sent when a directory expanded inline has been scanned
+type ExpandMsg struct {
+	Entry *scan.DirEntry
+	Dir   *scan.DirEntry
+	Error error
+}
+
+// toggleExpanded collapses entry if its children are listed inline, or
+// otherwise scans it in the background to list them
+func (m *Model) toggleExpanded(entry *scan.DirEntry) tea.Cmd {
+	if m.Expanded[entry.Path] && entry.Children != nil {
+		delete(m.Expanded, entry.Path)
+		m.rebuildVisibleDirs()
+		return nil
+	}
+	if entry.Children != nil {
+		// Scanned before, so there is nothing to wait for
+		m.setExpanded(entry.Path)
+		m.rebuildVisibleDirs()
+		return nil
+	}
+
+	m.Status = fmt.Sprintf("Scanning %s...", entry.Name)
+	opts := m.scanOptions()
+	// Cancelled along with the listing the entry is in
+	ctx := m.scanCtx
+	return func() tea.Msg {
+		dir, err := scanner.ScanDir(ctx, entry.Path, entry.ParentDir, entry.Level, opts)
+		if err != nil {
+			err = scan.Cause(ctx, err)
+		}
+		return ExpandMsg{Entry: entry, Dir: dir, Error: err}
+	}
+}
+
+// applyExpand lists the scanned children below their entry, unless the user
+// has moved to another directory in the meantime
+func (m *Model) applyExpand(msg ExpandMsg) {
+	found := false
+	for _, entry := range m.VisibleDirs {
+		if entry == msg.Entry {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return
+	}
+	if errors.Is(msg.Error, context.Canceled) {
+		m.Status = ""
+		return
+	}
+	if msg.Error != nil {
+		m.Status = fmt.Sprintf("Cannot expand %s: %v", msg.Entry.Name, msg.Error)
+		return
+	}
+
+	for _, child := range msg.Dir.Children {
+		child.ParentDir = msg.Entry
+	}
+	msg.Entry.Children = msg.Dir.Children
+	m.Status = ""
+	m.setExpanded(msg.Entry.Path)
+	m.rebuildVisibleDirs()
+}
+
+func (m *Model) setExpanded(path string) {
+	if m.Expanded == nil {
+		m.Expanded = make(map[string]bool)
+	}
+	m.Expanded[path] = true
+}
+
+// rebuildVisibleDirs refreshes the list after an expansion change, keeping
+// the cursor and scroll offset where they were
+func (m *Model) rebuildVisibleDirs() {
+	cursor, scroll := m.CursorPos, m.ScrollPos
+	m.updateVisibleDirs()
+	m.CursorPos, m.ScrollPos = cursor, scroll
+	m.ensureCursorVisible()
+}
+
+// toggleTreeMode switches between the tree and the flat listing, keeping the
+// selected entry, or the top-level directory containing it, under the cursor
+func (m *Model) toggleTreeMode() {
+	m.TreeMode = !m.TreeMode
+
+	entry := m.selectedEntry()
+	m.rebuildVisibleDirs()
+	for ; entry != nil; entry = entry.ParentDir {
+		for i, visible := range m.VisibleDirs {
+			if visible == entry {
+				m.CursorPos = i
+				m.ensureCursorVisible()
+				return
+			}
+		}
+	}
+}
+
+// toggleInline expands or collapses the selected directory inline, turning
+// on tree mode if it is off. On a row that can't be expanded it collapses
+// the directory listing that row and selects the directory instead.
+func (m *Model) toggleInline() tea.Cmd {
+	entry := m.selectedEntry()
+	if entry == nil || m.ShowTopDirs || isParentEntry(entry) {
+		return nil
+	}
+	// Without expansions the tree looks just like the flat listing
+	m.TreeMode = true
+	if entry.IsDir && !entry.Summary {
+		return m.toggleExpanded(entry)
+	}
+
+	parent := entry.ParentDir
+	if parent == nil || parent == m.RootDir || !m.Expanded[parent.Path] {
+		return nil
+	}
+	delete(m.Expanded, parent.Path)
+	m.rebuildVisibleDirs()
+	for i, visible := range m.VisibleDirs {
+		if visible == parent {
+			m.CursorPos = i
+			m.ensureCursorVisible()
+			break
+		}
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// ExportMsg is sent when an export started from the UI has been written
+type ExportMsg struct {
+	Path  string
+	Error error
+}
+
+// writeCSV writes one row per child of dir, with a header row and raw byte
+// sizes. A file given as dir gets the one row of its own.
+func writeCSV(w io.Writer, dir *scan.DirEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"name", "path", "size", "percent", "is_dir", "file_count"}); err != nil {
+		return err
+	}
+	rows := dir.Children
+	if !dir.IsDir {
+		rows = []*scan.DirEntry{dir}
+	}
+	for _, child := range rows {
+		row := []string{
+			child.Name,
+			child.Path,
+			strconv.FormatInt(child.Size, 10),
+			strconv.FormatFloat(child.Percent, 'f', 2, 64),
+			strconv.FormatBool(child.IsDir),
+			strconv.FormatInt(child.FileCount, 10),
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// exportCSV writes the children of dir to the CSV file at path, or to stdout for "-"
+func exportCSV(path string, dir *scan.DirEntry) error {
+	if path == "-" {
+		return writeCSV(os.Stdout, dir)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeCSV(f, dir); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportJSON writes dir and everything loaded below it as indented JSON to
+// the file at path, or to stdout for "-"
+func exportJSON(path string, dir *scan.DirEntry) error {
+	if path == "-" {
+		return writeJSON(os.Stdout, dir)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeJSON(f, dir); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeJSON encodes dir with its nested children
+func writeJSON(w io.Writer, dir *scan.DirEntry) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(dir)
+}
+
+// foldedFrame makes a path component safe for a folded stack, where ";"
+// separates frames and a line holds one stack
+var foldedFrame = strings.NewReplacer(";", ":", "\n", " ")
+
+// writeFolded writes the tree below root in the folded stacks format read by
+// flamegraph.pl and speedscope: one "root;dir;subdir bytes" line per directory
+// with files directly inside it. Each line only counts those files, the tools
+// add up the nested lines to get a directory's total.
+func writeFolded(ctx context.Context, w io.Writer, root string) error {
+	root = scan.AbsPath(root)
+	type stack struct {
+		path   string
+		direct int64
+	}
+	var stacks []stack
+	totals := scanner.FullDirSize(ctx, root, 0, func(path string, totals scan.Totals) {
+		stacks = append(stacks, stack{path, totals.Direct})
+	})
+	if err := ctx.Err(); err != nil {
+		return scan.Cause(ctx, err)
+	}
+	stacks = append(stacks, stack{root, totals.Direct})
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].path < stacks[j].path })
+
+	bw := bufio.NewWriter(w)
+	for _, s := range stacks {
+		if s.direct == 0 {
+			continue
+		}
+		frames := []string{foldedFrame.Replace(filepath.Base(root))}
+		if rel, _ := filepath.Rel(root, s.path); rel != "." {
+			for _, name := range strings.Split(rel, string(filepath.Separator)) {
+				frames = append(frames, foldedFrame.Replace(name))
+			}
+		}
+		fmt.Fprintf(bw, "%s %d\n", strings.Join(frames, ";"), s.direct)
+	}
+	return bw.Flush()
+}
+
+// exportFolded writes the folded stacks of root to the file at path, or to stdout for "-"
+func exportFolded(ctx context.Context, path, root string) error {
+	if path == "-" {
+		return writeFolded(ctx, os.Stdout, root)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := writeFolded(ctx, f, root); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// exportCSVCmd writes the current directory to a timestamped CSV file in the working directory
+func (m Model) exportCSVCmd() tea.Cmd {
+	dir := m.RootDir
+	return func() tea.Msg {
+		path := fmt.Sprintf("usage-%s.csv", time.Now().Format("20060102-150405"))
+		return ExportMsg{path, exportCSV(path, dir)}
+	}
+}
+
+// exportJSONCmd writes the loaded tree to a timestamped JSON file in the working directory
+func (m Model) exportJSONCmd() tea.Cmd {
+	dir := m.RootDir
+	return func() tea.Msg {
+		path := fmt.Sprintf("usage-%s.json", time.Now().Format("20060102-150405"))
+		return ExportMsg{path, exportJSON(path, dir)}
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// startFilter enters filter mode, where typed keys narrow down the listing
+func (m *Model) startFilter() {
+	m.Filtering = true
+	m.Filter = ""
+}
+
+// updateFilter edits the filter query while in filter mode. Keys that don't
+// edit it, such as the arrows and enter, are left to the listing, and handled
+// is false for them.
+func (m Model) updateFilter(msg tea.KeyMsg) (model tea.Model, cmd tea.Cmd, handled bool) {
+	switch msg.Type {
+	case tea.KeyEsc:
+		m.clearFilter()
+	case tea.KeyBackspace:
+		if runes := []rune(m.Filter); len(runes) > 0 {
+			m.Filter = string(runes[:len(runes)-1])
+			m.updateVisibleDirs()
+		}
+	case tea.KeySpace:
+		m.Filter += " "
+		m.updateVisibleDirs()
+	case tea.KeyRunes:
+		m.Filter += string(msg.Runes)
+		m.updateVisibleDirs()
+	default:
+		return m, nil, false
+	}
+	return m, nil, true
+}
+
+// clearFilter leaves filter mode and lists everything again, keeping the
+// selected entry under the cursor
+func (m *Model) clearFilter() {
+	selected := m.selectedEntry()
+	m.Filtering = false
+	m.Filter = ""
+	m.updateVisibleDirs()
+	if selected != nil && m.selectPath(selected.Path) {
+		m.ensureCursorVisible()
+	}
+}
+
+// applyFilter drops the listed entries whose names don't contain the filter
+// query, ignoring case
+func (m *Model) applyFilter() {
+	if m.Filter == "" {
+		return
+	}
+	query := strings.ToLower(m.Filter)
+	matches := m.VisibleDirs[:0]
+	for _, entry := range m.VisibleDirs {
+		if !isParentEntry(entry) && strings.Contains(strings.ToLower(entry.Name), query) {
+			matches = append(matches, entry)
+		}
+	}
+	m.VisibleDirs = matches
+}
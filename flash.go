@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// flashDuration is how long changed rows stay highlighted after a re-scan
+const flashDuration = time.Second
+
+// FlashDoneMsg ends the highlight started by flashChanges call number ID
+type FlashDoneMsg struct {
+	ID int
+}
+
+// flashChanges highlights the rows of the current directory that are new or
+// changed size compared to old, notes how many disappeared, and returns the
+// command that clears the highlight again
+func (m *Model) flashChanges(old []*scan.DirEntry) tea.Cmd {
+	oldSizes := make(map[string]int64)
+	for _, entry := range old {
+		if entry.Level == 1 && !entry.Summary {
+			oldSizes[entry.Path] = entry.Size
+		}
+	}
+
+	m.Flash = make(map[string]bool)
+	for _, entry := range m.VisibleDirs {
+		if entry.Level != 1 || entry.Summary {
+			continue
+		}
+		if size, ok := oldSizes[entry.Path]; !ok || size != entry.Size {
+			m.Flash[entry.Path] = true
+		}
+		delete(oldSizes, entry.Path)
+	}
+	if removed := len(oldSizes); removed > 0 {
+		m.Status = fmt.Sprintf("%d removed, %d changed", removed, len(m.Flash))
+	}
+
+	m.flashID++
+	id := m.flashID
+	return tea.Tick(flashDuration, func(time.Time) tea.Msg {
+		return FlashDoneMsg{id}
+	})
+}
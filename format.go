@@ -0,0 +1,37 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/dustin/go-humanize"
+)
+
+// sizeUnits are the SI units used by humanize.Bytes
+var sizeUnits = []string{"B", "kB", "MB", "GB", "TB", "PB", "EB"}
+
+// formatSize renders n as a 10-column size. Unless plain is set the number
+// and unit get separate sub-columns, so decimal points and units line up
+// vertically between rows (e.g. "   1.2 GB " above " 999.0 kB ").
+func formatSize(n int64, plain bool) string {
+	if plain {
+		return fmt.Sprintf("%10s", humanize.Bytes(uint64(n)))
+	}
+
+	if n < 1000 {
+		// Whole bytes, with the digits aligned to the integer part of the other rows
+		return fmt.Sprintf(" %4d   %-2s", n, sizeUnits[0])
+	}
+
+	exp := int(math.Log(float64(n)) / math.Log(1000))
+	if exp >= len(sizeUnits) {
+		exp = len(sizeUnits) - 1
+	}
+	value := float64(n) / math.Pow(1000, float64(exp))
+	if value >= 999.95 && exp < len(sizeUnits)-1 {
+		// Would round up to "1000.0", show it in the next unit instead
+		exp++
+		value /= 1000
+	}
+	return fmt.Sprintf(" %6.1f %-2s", value, sizeUnits[exp])
+}
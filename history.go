@@ -0,0 +1,49 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// maxPathHistory caps PathHistory, dropping the oldest directories first
+const maxPathHistory = 100
+
+// pushHistory remembers path as the directory left for another one. A
+// directory left twice in a row is only remembered once.
+func (m *Model) pushHistory(path string) {
+	if n := len(m.PathHistory); n > 0 && m.PathHistory[n-1] == path {
+		return
+	}
+	m.PathHistory = append(m.PathHistory, path)
+	if len(m.PathHistory) > maxPathHistory {
+		m.PathHistory = m.PathHistory[len(m.PathHistory)-maxPathHistory:]
+	}
+}
+
+// historyBack returns to the directory visited before the current one
+func (m *Model) historyBack() tea.Cmd {
+	n := len(m.PathHistory)
+	if n == 0 {
+		m.Status = "No earlier directory"
+		return nil
+	}
+	path := m.PathHistory[n-1]
+	m.PathHistory = m.PathHistory[:n-1]
+	m.returning = true
+	return func() tea.Msg {
+		return LoadingMsg{Path: path}
+	}
+}
+
+// breadcrumbs separates the directories of path with ›
+func breadcrumbs(path string) string {
+	sep := string(filepath.Separator)
+	crumbs := strings.Split(strings.TrimSuffix(path, sep), sep)
+	if crumbs[0] == "" {
+		// The root of an absolute path
+		crumbs[0] = sep
+	}
+	return strings.Join(crumbs, " › ")
+}
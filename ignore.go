@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// IgnoreMsg is sent when a path has been added to or removed from the ignore
+// list in the config file
+type IgnoreMsg struct {
+	Path    string
+	Ignored bool
+	Error   error
+}
+
+// ignoreCmd saves the change to the ignore list in the config file
+func (m Model) ignoreCmd(path string, ignored bool) tea.Cmd {
+	configPath := m.ConfigPath
+	return func() tea.Msg {
+		if configPath == "" {
+			return IgnoreMsg{path, ignored, fmt.Errorf("no config file to save to")}
+		}
+		return IgnoreMsg{path, ignored, updateIgnoreList(configPath, path, ignored)}
+	}
+}
+
+// applyIgnore updates the ignore list once it has been saved and re-scans
+// the current directory, whose totals change with it
+func (m *Model) applyIgnore(msg IgnoreMsg) tea.Cmd {
+	if msg.Error != nil {
+		m.Status = fmt.Sprintf("Saving ignore list failed: %v", msg.Error)
+		return nil
+	}
+
+	scanner.SetIgnored(msg.Path, msg.Ignored)
+	if msg.Ignored {
+		m.Status = fmt.Sprintf("Ignoring %s", msg.Path)
+	} else {
+		m.Status = fmt.Sprintf("No longer ignoring %s", msg.Path)
+	}
+	m.IgnoreList = scanner.Ignored()
+	if m.IgnoreCursor >= len(m.IgnoreList) {
+		m.IgnoreCursor = len(m.IgnoreList) - 1
+	}
+	if m.IgnoreCursor < 0 {
+		m.IgnoreCursor = 0
+	}
+
+	scanner.Invalidate(msg.Path)
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
+	}
+}
+
+// updateIgnorePane handles keys while the ignore list is shown
+func (m Model) updateIgnorePane(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "U", "esc":
+		m.ShowIgnored = false
+	case "up", "k":
+		if m.IgnoreCursor > 0 {
+			m.IgnoreCursor--
+		}
+	case "down", "j":
+		if m.IgnoreCursor < len(m.IgnoreList)-1 {
+			m.IgnoreCursor++
+		}
+	case "d", "delete", "x":
+		if m.IgnoreCursor < len(m.IgnoreList) {
+			return m, m.ignoreCmd(m.IgnoreList[m.IgnoreCursor], false)
+		}
+	}
+	return m, nil
+}
+
+// ignorePaneView lists the ignored paths with the one under the cursor highlighted
+func (m Model) ignorePaneView(headerStyle lipgloss.Style) string {
+	var s strings.Builder
+
+	header := fmt.Sprintf("%s ignored paths (d to stop ignoring, U/esc to close)", m.formatCount(int64(len(m.IgnoreList))))
+	s.WriteString(headerStyle.Render(header) + "\n")
+
+	if len(m.IgnoreList) == 0 {
+		s.WriteString("  Nothing ignored; press I on an entry to ignore it\n")
+	}
+
+	selectedStyle := m.theme().Selected
+	maxVisible := m.Height - 2
+	start := 0
+	if m.IgnoreCursor >= maxVisible {
+		start = m.IgnoreCursor - maxVisible + 1
+	}
+	for i := start; i < len(m.IgnoreList) && i < start+maxVisible; i++ {
+		if i == m.IgnoreCursor {
+			s.WriteString(selectedStyle.Render("> "+m.IgnoreList[i]) + "\n")
+		} else {
+			s.WriteString("  " + m.IgnoreList[i] + "\n")
+		}
+	}
+
+	if m.Status != "" {
+		s.WriteString(m.theme().Muted.Render(m.Status))
+	}
+	return s.String()
+}
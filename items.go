@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"usage/scan"
+)
+
+// ItemCounts is what the item count column shows, if it is shown
+type ItemCounts int
+
+const (
+	ItemCountsHidden ItemCounts = iota
+	// ItemCountsDirect counts the files and directories directly inside
+	ItemCountsDirect
+	// ItemCountsRecursive counts every file and directory below, as summed
+	// along with the sizes
+	ItemCountsRecursive
+)
+
+// itemCountNames describe each ItemCounts, in the order n cycles through them
+var itemCountNames = []string{"hidden", "direct", "recursive"}
+
+// itemsWidth is the width of the item count column, up to "99,999,999 items"
+const itemsWidth = 10 + len(" items")
+
+// cycleItemCounts shows the item count column with the direct children,
+// then with everything below, then hides it again
+func (m *Model) cycleItemCounts() {
+	m.ItemCounts = (m.ItemCounts + 1) % ItemCounts(len(itemCountNames))
+	switch m.ItemCounts {
+	case ItemCountsDirect:
+		m.Status = "Counting the items directly inside each directory"
+	case ItemCountsRecursive:
+		m.Status = "Counting all items below each directory"
+	default:
+		m.Status = "Item counts hidden"
+	}
+}
+
+// itemCount formats the item count column for entry, e.g. "1,204 items".
+// It is blank for files, the parent link and the summary row, and "?" for
+// directories that couldn't be listed.
+func (m Model) itemCount(entry *scan.DirEntry) string {
+	if !entry.IsDir || entry.Summary || isParentEntry(entry) {
+		return strings.Repeat(" ", itemsWidth)
+	}
+	if entry.ReadError != nil {
+		return fmt.Sprintf("%*s", itemsWidth, "?")
+	}
+	n := int64(entry.ItemCount)
+	if m.ItemCounts == ItemCountsRecursive {
+		n = entry.FileCount + entry.DirCount
+	}
+	unit := "items"
+	if n == 1 {
+		unit = "item "
+	}
+	return fmt.Sprintf("%*s %s", itemsWidth-len(" items"), m.formatCount(n), unit)
+}
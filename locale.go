@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// systemLocale returns the locale used for numbers, following the POSIX
+// precedence of LC_ALL, LC_NUMERIC and LANG
+func systemLocale() string {
+	for _, name := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// numberPrinter returns the printer grouping digits for a locale name such as
+// "de_DE.UTF-8" or "fr-CA", or nil for the C and POSIX locales, which don't
+// group them. Unknown and empty names group with a comma.
+func numberPrinter(locale string) *message.Printer {
+	name, _, _ := strings.Cut(locale, ".")
+	name, _, _ = strings.Cut(name, "@")
+	if name == "C" || name == "POSIX" {
+		return nil
+	}
+	return message.NewPrinter(language.Make(strings.ReplaceAll(name, "_", "-")))
+}
+
+// formatCount formats a count of entries with the digit grouping of the configured locale
+func (m Model) formatCount(n int64) string {
+	if m.Numbers == nil {
+		return strconv.FormatInt(n, 10)
+	}
+	return m.Numbers.Sprint(n)
+}
@@ -0,0 +1,26 @@
+package main
+
+import "testing"
+
+func TestFormatCountGroupsDigitsByLocale(t *testing.T) {
+	cases := []struct {
+		locale string
+		want   string
+	}{
+		{"", "1,234,567"},
+		{"en_US.UTF-8", "1,234,567"},
+		{"de_DE.UTF-8", "1.234.567"},
+		{"de_CH.UTF-8", "1’234’567"},
+		{"fr_FR@euro", "1 234 567"},
+		{"hi_IN", "12,34,567"},
+		{"en-IN", "12,34,567"},
+		{"C", "1234567"},
+		{"POSIX", "1234567"},
+	}
+	for _, c := range cases {
+		m := Model{Numbers: numberPrinter(c.locale)}
+		if got := m.formatCount(1234567); got != c.want {
+			t.Errorf("formatCount(1234567) in %q = %q, want %q", c.locale, got, c.want)
+		}
+	}
+}
@@ -1,47 +1,64 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
+	"io/fs"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime/debug"
 	"sort"
+	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/dustin/go-humanize"
-)
+	"golang.org/x/text/message"
 
-// Global cache for directory sizes
-var (
-	sizeCache  = make(map[string]int64)
-	cacheMutex sync.RWMutex
+	"usage/scan"
 )
 
-// DirEntry represents a directory with its size and children
-type DirEntry struct {
-	Name      string
-	Path      string
-	Size      int64
-	Percent   float64
-	Children  []*DirEntry
-	IsDir     bool
-	Level     int
-	ParentDir *DirEntry
-}
+// scanner sizes the directories of every scan in the session and caches
+// their totals
+var scanner = scan.New()
 
-// LoadingMsg is sent when loading starts
+// LoadingMsg is sent when loading starts. A Refresh reloads the current
+// directory in the background, keeping the view and cursor where they are.
 type LoadingMsg struct {
-	Path string
+	Path    string
+	Refresh bool
 }
 
 // LoadingCompleteMsg is sent when loading completes
 type LoadingCompleteMsg struct {
-	Dir   *DirEntry
+	Dir      *scan.DirEntry
+	Error    error
+	Quota    *QuotaInfo
+	Disk     *DiskSpace
+	Duration time.Duration
+	// FileCount and DirCount are the files and directories counted below Dir
+	FileCount int
+	DirCount  int
+	Refresh   bool
+	// Scan is the scan that loaded Dir, see Model.scanID
+	Scan int
+}
+
+// TopDirsMsg is sent when the largest-directories report, or with Files the
+// largest-files report, has been collected
+type TopDirsMsg struct {
+	Dirs  []*scan.DirEntry
+	Files bool
 	Error error
+	// Scan is the scan that collected Dirs, see Model.scanID
+	Scan int
 }
 
 // SpinnerMsg for spinner animation
@@ -49,16 +66,157 @@ type SpinnerMsg time.Time
 
 // Model represents the application state
 type Model struct {
-	RootDir     *DirEntry
+	RootDir     *scan.DirEntry
 	CursorPos   int
 	ScrollPos   int
-	VisibleDirs []*DirEntry
+	VisibleDirs []*scan.DirEntry
 	Error       error
 	Loading     bool
 	LoadingPath string
 	SpinnerIdx  int
 	ShowFiles   bool
 	Height      int
+	Confirm     *Confirmation
+	AltScreen   bool
+	TopDirsN    int
+	ShowTopDirs bool
+	// TopFiles is set when the report shown with ShowTopDirs lists files
+	TopFiles  bool
+	StartPath string
+	Label     string
+	// RootRelative shows percentages relative to RootDir instead of each entry's parent
+	RootRelative bool
+	// MaxChildren caps the rows listed per directory (0 lists all); the rest
+	// are rolled into a summary row until the directory is added to ShowAllIn
+	MaxChildren int
+	ShowAllIn   map[string]bool
+	// MinSize leaves entries smaller than it out of the listing, 0 for none
+	MinSize int64
+	// Expanded holds the directories below RootDir whose children are listed inline
+	Expanded map[string]bool
+	// TreeMode lists the Expanded directories inline and makes enter expand
+	// directories instead of entering them; otherwise the list is flat
+	TreeMode bool
+	// HomeRelative shows paths below Home as ~/...
+	HomeRelative bool
+	Home         string
+	// Numbers groups the digits of counts as the locale does, nil for no grouping
+	Numbers *message.Printer
+	// ConfigPath is the config file the ignore list is saved to
+	ConfigPath string
+	// ShowIgnored shows the ignore list, IgnoreList, instead of the directory
+	ShowIgnored  bool
+	IgnoreList   []string
+	IgnoreCursor int
+	// PathHistory holds the directories left for another one, most recent
+	// last, for [ to return to
+	PathHistory []string
+	// returning is set while a directory taken off PathHistory loads, so
+	// leaving the current one doesn't put it back
+	returning bool
+	// Filter lists only the entries whose names contain it, ignoring case.
+	// While Filtering, typed keys edit it.
+	Filter    string
+	Filtering bool
+	// SortMode orders the listed children
+	SortMode SortMode
+	// ShowAllocated adds columns with the apparent and the allocated size and their ratio
+	ShowAllocated bool
+	// Plan holds the entries selected for deletion to free PlanTarget bytes, while planning
+	Plan       map[string]bool
+	PlanTarget int64
+	// SessionName is the session saved on quit, if any
+	SessionName string
+	// Trash moves deleted entries to the trash; trashed holds those moved
+	// there in this session, the last one on top for undo
+	Trash   bool
+	trashed []trashedEntry
+	// ShowFullName adds the selected entry's full path to the footer, however long
+	ShowFullName bool
+	// ShowRootInfo adds a line with the current directory's mode, owner and mtime below the header
+	ShowRootInfo bool
+	// PercentDecimals is the number of decimals (0-2) in the percent column
+	PercentDecimals int
+	// ShowCounts adds the number of immediate subdirectories and files to directory rows
+	ShowCounts bool
+	// ItemCounts adds a column with the number of items in each directory
+	ItemCounts ItemCounts
+	// ShowBar adds a bar splitting each entry's share into its own files and its subdirectories
+	ShowBar bool
+	// ShowErrors replaces the listing with a scrollable pane of ScanErrors
+	ShowErrors  bool
+	ScanErrors  []scan.Error
+	ErrorScroll int
+	Width       int
+	ShowPreview bool
+	// WalkScan sizes a new directory with a single WalkDir pass before scanning it
+	WalkScan bool
+	// MaxDepth limits how many directory levels are summed for each listed
+	// directory (0 for all), for trees too large or slow to scan completely
+	MaxDepth int
+	// AdaptiveScan lists directories with the size of their direct files first
+	// and refines them with deeper passes in the background
+	AdaptiveScan bool
+	Quota        *QuotaInfo
+	// Disk is the space of the filesystem holding RootDir, shown in the header
+	Disk *DiskSpace
+	// QuickSelect maps the digits 1-9 to the first nine entries
+	QuickSelect bool
+	Palette     Palette
+	PlainSizes  bool
+	Input       *InputPrompt
+	// Status is a one-off message shown until the next key press
+	Status string
+	// HiddenSummary notes how many hidden entries are left out of the totals
+	HiddenSummary bool
+	// ScanDuration, ScanFileCount and ScanDirCount describe the last scan of
+	// the current directory. Hidden entries only count while they are shown.
+	ScanDuration  time.Duration
+	ScanFileCount int
+	ScanDirCount  int
+	// StartSize is the total of StartPath from the first scan, kept across navigation
+	StartSize int64
+	// PendingKey is the first key of a two-key command such as "zz"
+	PendingKey string
+	// scanCtx and scanCancel belong to the directory scan started last
+	scanCtx    context.Context
+	scanCancel context.CancelFunc
+	// scanID numbers the scans started, so messages from superseded ones are
+	// dropped instead of replacing the listing or the loading state
+	scanID int
+	// progress is updated by the directory scan started last
+	progress *scan.Progress
+	// ScannedCount and ScanCurrent are the entries visited by the running scan
+	// and the directory it is in
+	ScannedCount int64
+	ScanCurrent  string
+	// Flash holds the paths of rows highlighted after a re-scan changed them
+	Flash   map[string]bool
+	flashID int
+	// flashBase is the listing from before a deletion, which the re-scan
+	// that follows is compared with so the deleted rows count as removed
+	flashBase []*scan.DirEntry
+	// frame is shared by the copies of the model so View can reuse it
+	frame *frameCache
+	// previews caches the heads of files read for the preview pane by path,
+	// shared by the copies of the model like frame
+	previews map[string]previewText
+	// lastClick and lastClickRow tell a double click from two single ones
+	lastClick    time.Time
+	lastClickRow int
+}
+
+// Confirmation is a pending yes/no question shown at the bottom of the view
+type Confirmation struct {
+	Prompt string
+	OnYes  tea.Cmd
+}
+
+// InputPrompt is a pending line of text entered at the bottom of the view
+type InputPrompt struct {
+	Prompt   string
+	Value    string
+	OnSubmit func(m *Model, value string) tea.Cmd
 }
 
 // ExecuteFileMsg is sent when file execution completes
@@ -74,7 +232,11 @@ func (m Model) Init() tea.Cmd {
 	showFiles := os.Getenv("USAGE_SHOW_FILES") != "false"
 	m.ShowFiles = showFiles
 	m.Height = 20 // Default height, will be updated when we get window size
-	return tea.Batch(m.doSpinner(), tea.EnterAltScreen)
+	cmds := []tea.Cmd{m.doSpinner(), m.refineCmd()}
+	if m.AltScreen {
+		cmds = append(cmds, tea.EnterAltScreen)
+	}
+	return tea.Batch(cmds...)
 }
 
 func (m Model) doSpinner() tea.Cmd {
@@ -83,73 +245,378 @@ func (m Model) doSpinner() tea.Cmd {
 	})
 }
 
-func (m Model) loadDirectory(path string) tea.Cmd {
-	return func() tea.Msg {
-		dir, err := scanDirectoryWithCache(path, nil, 0, m.ShowFiles)
+func (m Model) loadDirectory(ctx context.Context, path string, refresh bool) tea.Cmd {
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = LoadingCompleteMsg{Error: scanPanic(r), Refresh: refresh, Scan: m.scanID}
+			}
+		}()
+		start := time.Now()
+		if m.WalkScan && m.MaxDepth == 0 && !scanner.FollowSymlinks {
+			// Priming sizes everything, so it would defeat a depth limit, and
+			// it never follows symlinks
+			scanner.Prime(ctx, path)
+		}
+		dir, err := scanner.Scan(ctx, path, m.scanOptions())
 		if err != nil {
-			return LoadingCompleteMsg{nil, err}
+			return LoadingCompleteMsg{Error: scan.Cause(ctx, err), Refresh: refresh, Scan: m.scanID}
 		}
-		dir.Percent = 100.0
-		return LoadingCompleteMsg{dir, nil}
+		return LoadingCompleteMsg{Dir: dir, Quota: loadQuota(path), Disk: loadDiskSpace(path), Duration: time.Since(start),
+			FileCount: int(dir.FileCount), DirCount: int(dir.DirCount), Refresh: refresh, Scan: m.scanID}
+	}
+}
+
+// scanOptions returns the scan settings selected for this session
+func (m Model) scanOptions() scan.Options {
+	return scan.Options{
+		ShowFiles:     m.ShowFiles,
+		HiddenSummary: m.HiddenSummary,
+		Shallow:       m.AdaptiveScan,
+		MaxDepth:      m.MaxDepth,
 	}
 }
 
-// getCachedSize returns cached size or calculates it once
-func getCachedSize(path string) int64 {
-	cacheMutex.RLock()
-	if size, exists := sizeCache[path]; exists {
-		cacheMutex.RUnlock()
-		return size
+// loadQuota returns the quota that applies to path, or nil when there is none
+func loadQuota(path string) *QuotaInfo {
+	if quota, ok := getQuota(path); ok {
+		return &quota
 	}
-	cacheMutex.RUnlock()
+	return nil
+}
+
+// scanPanic logs a panic recovered from a scan and turns it into an error to
+// show instead, so a bug or a pathological filesystem doesn't take down the UI
+func scanPanic(recovered any) error {
+	log.Printf("panic during scan: %v\n%s", recovered, debug.Stack())
+	return fmt.Errorf("scan failed unexpectedly: %v", recovered)
+}
+
+// loadTopDirs collects the n largest directories anywhere below path
+func (m Model) loadTopDirs(ctx context.Context, path string, total int64, n int) tea.Cmd {
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = TopDirsMsg{Error: scanPanic(r), Scan: m.scanID}
+			}
+		}()
+		if _, err := os.Stat(path); err != nil {
+			return TopDirsMsg{Error: err, Scan: m.scanID}
+		}
+
+		var dirs []*scan.DirEntry
+		scanner.FullDirSize(ctx, path, 0, func(dirPath string, totals scan.Totals) {
+			if ctx.Err() != nil {
+				// The subtree may have been cut short
+				return
+			}
 
-	// Calculate size with full recursion (but only once)
-	size := calculateFullDirSize(path)
+			// Every subdirectory size is known now, so remember it for later navigation
+			scanner.Store(dirPath, totals)
+
+			dirs = append(dirs, &scan.DirEntry{
+				Name:      dirPath,
+				Path:      dirPath,
+				Size:      totals.Size,
+				FileCount: totals.Files,
+				DirCount:  totals.Dirs,
+				ItemCount: int(totals.ChildDirs + totals.ChildFiles),
+				Apparent:  totals.Apparent,
+				Allocated: totals.Allocated,
+				IsDir:     true,
+			})
+		})
+
+		if ctx.Err() != nil {
+			return TopDirsMsg{Error: scan.Cause(ctx, ctx.Err()), Scan: m.scanID}
+		}
+
+		scan.SortBySize(dirs)
+		if len(dirs) > n {
+			dirs = dirs[:n]
+		}
 
-	cacheMutex.Lock()
-	sizeCache[path] = size
-	cacheMutex.Unlock()
+		if total > 0 {
+			for _, dir := range dirs {
+				dir.Percent = float64(dir.Size) / float64(total) * 100
+			}
+		}
 
-	return size
+		return TopDirsMsg{Dirs: dirs, Scan: m.scanID}
+	}
 }
 
-// calculateFullDirSize does full recursive calculation
-func calculateFullDirSize(path string) int64 {
-	var size int64
+// loadTopFiles collects the n largest files anywhere below path, named by
+// their path relative to it
+func (m Model) loadTopFiles(ctx context.Context, path string, total int64, n int) tea.Cmd {
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = TopDirsMsg{Files: true, Error: scanPanic(r), Scan: m.scanID}
+			}
+		}()
+		if _, err := os.Stat(path); err != nil {
+			return TopDirsMsg{Files: true, Error: err, Scan: m.scanID}
+		}
 
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return 0
+		var files []*scan.DirEntry
+		scanner.WalkFiles(ctx, path, func(filePath string, size int64) {
+			name, _ := filepath.Rel(path, filePath)
+			files = append(files, &scan.DirEntry{Name: name, Path: filePath, Size: size, FileCount: 1})
+			if len(files) > 2*n {
+				// Only the largest n can make it, so the rest needn't be kept
+				scan.SortBySize(files)
+				files = files[:n]
+			}
+		})
+
+		if ctx.Err() != nil {
+			return TopDirsMsg{Files: true, Error: scan.Cause(ctx, ctx.Err()), Scan: m.scanID}
+		}
+
+		scan.SortBySize(files)
+		if len(files) > n {
+			files = files[:n]
+		}
+
+		if total > 0 {
+			for _, file := range files {
+				file.Percent = float64(file.Size) / float64(total) * 100
+			}
+		}
+
+		return TopDirsMsg{Dirs: files, Files: true, Scan: m.scanID}
 	}
+}
+
+// startScan cancels any scan still running and returns the context for a new one
+func (m *Model) startScan() context.Context {
+	m.cancelScan()
+	m.scanID++
+	ctx, cancel := context.WithCancel(context.Background())
+	m.progress = &scan.Progress{}
+	m.scanCtx, m.scanCancel = scanner.WithErrorMode(scan.WithProgress(ctx, m.progress)), cancel
+	return m.scanCtx
+}
 
-	for _, entry := range entries {
-		if strings.HasPrefix(entry.Name(), ".") {
-			continue
+// cancelScan stops the running scan, if any
+func (m *Model) cancelScan() {
+	if m.scanCancel != nil {
+		m.scanCancel()
+		m.scanCancel = nil
+	}
+}
+
+// applyRefresh swaps in a background re-scan of the current directory while
+// keeping the selected entry and scroll position, so the list doesn't jump.
+// Rows that changed are highlighted briefly.
+func (m *Model) applyRefresh(msg LoadingCompleteMsg) tea.Cmd {
+	if msg.Error != nil {
+		m.Status = fmt.Sprintf("Refresh failed: %v", msg.Error)
+		return nil
+	}
+
+	m.RootDir = msg.Dir
+	m.Quota = msg.Quota
+	m.Disk = msg.Disk
+	m.ScanDuration = msg.Duration
+	m.ScanFileCount, m.ScanDirCount = msg.FileCount, msg.DirCount
+	if m.ShowTopDirs {
+		// The report keeps its own list until it is closed
+		return nil
+	}
+
+	var selected string
+	if entry := m.selectedEntry(); entry != nil {
+		selected = entry.Path
+	}
+	cursor, scroll := m.CursorPos, m.ScrollPos
+	old := m.VisibleDirs
+	if m.flashBase != nil {
+		old, m.flashBase = m.flashBase, nil
+	}
+
+	m.updateVisibleDirs()
+	m.CursorPos, m.ScrollPos = cursor, scroll
+	m.selectPath(selected)
+	m.ensureCursorVisible()
+	return m.flashChanges(old)
+}
+
+// selectPath moves the cursor to the entry with the given path and reports
+// whether it was found
+func (m *Model) selectPath(path string) bool {
+	for i, entry := range m.VisibleDirs {
+		if entry.Path == path {
+			m.CursorPos = i
+			return true
 		}
+	}
+	return false
+}
 
-		childPath := filepath.Join(path, entry.Name())
-		info, err := entry.Info()
-		if err != nil {
-			continue
+// selectReturnedFrom moves the cursor to the entry of the current directory
+// that leads to from, the directory left for it, so going up lands on the
+// directory just come out of. The cursor stays put when from isn't below.
+func (m *Model) selectReturnedFrom(from string) {
+	if from == "" || from == m.RootDir.Path || !scan.IsWithin(from, m.RootDir.Path) {
+		return
+	}
+	rel, _ := filepath.Rel(m.RootDir.Path, from)
+	name, _, _ := strings.Cut(rel, string(filepath.Separator))
+	m.selectPath(filepath.Join(m.RootDir.Path, name))
+}
+
+// clampCursor keeps CursorPos within VisibleDirs, which may be empty
+func (m *Model) clampCursor() {
+	if len(m.VisibleDirs) == 0 {
+		m.CursorPos = 0
+		m.ScrollPos = 0
+		return
+	}
+	if m.CursorPos >= len(m.VisibleDirs) {
+		m.CursorPos = len(m.VisibleDirs) - 1
+	}
+	if m.CursorPos < 0 {
+		m.CursorPos = 0
+	}
+}
+
+// footerLines returns the status lines rendered below the listing
+func (m Model) footerLines() []string {
+	var lines []string
+
+	// Details about the current directory share one line
+	var info []string
+	if m.RootDir != nil && !m.ShowTopDirs {
+		if summary := topExtensions(m.RootDir, 3); summary != "" {
+			info = append(info, summary)
+		}
+		if m.HiddenSummary && m.RootDir.HiddenCount > 0 {
+			info = append(info, fmt.Sprintf("%s hidden items (%s) not included", m.formatCount(int64(m.RootDir.HiddenCount)),
+				humanize.Bytes(uint64(m.RootDir.HiddenSize))))
+		}
+		if m.ScanDuration > 0 {
+			info = append(info, fmt.Sprintf("scanned %s items in %s", m.formatCount(int64(m.ScanFileCount+m.ScanDirCount)),
+				m.ScanDuration.Round(time.Millisecond)))
+		}
+		if m.refining() {
+			info = append(info, "sizes marked … are still growing")
 		}
+		if m.truncated() {
+			info = append(info, fmt.Sprintf("sizes marked (+) only count %d levels", m.MaxDepth))
+		}
+		if n := m.RootDir.Unreadable; n > 0 {
+			what := "directories"
+			if n == 1 {
+				what = "directory"
+			}
+			info = append(info, fmt.Sprintf("%s %s unreadable, sizes are incomplete", m.formatCount(n), what))
+		}
+	}
+	if len(info) > 0 {
+		lines = append(lines, strings.Join(info, "  |  "))
+	}
 
-		if info.IsDir() {
-			size += calculateFullDirSize(childPath) // Recursive call
-		} else {
-			size += info.Size()
+	if m.Quota != nil {
+		lines = append(lines, fmt.Sprintf("%s quota: %s of %s (%.0f%%)", m.Quota.Kind,
+			humanize.Bytes(uint64(m.Quota.Used)), humanize.Bytes(uint64(m.Quota.Limit)),
+			float64(m.Quota.Used)/float64(m.Quota.Limit)*100))
+	}
+
+	if m.Plan != nil {
+		lines = append(lines, m.planSummary())
+	}
+
+	if m.ShowFullName {
+		if entry := m.selectedEntry(); entry != nil && !entry.Summary {
+			lines = append(lines, wrapRunes(m.displayPath(entry.Path), m.Width)...)
+		}
+	}
+	return lines
+}
+
+// wrapRunes splits s into lines of at most width runes, so long paths don't
+// wrap on their own and push the view out of place. A width of 0 keeps s whole.
+func wrapRunes(s string, width int) []string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return []string{s}
+	}
+	var lines []string
+	for len(runes) > width {
+		lines = append(lines, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(lines, string(runes))
+}
+
+// truncated reports whether any child of the current directory stops at MaxDepth
+func (m Model) truncated() bool {
+	for _, child := range m.RootDir.Children {
+		if child.Truncated {
+			return true
 		}
 	}
+	return false
+}
+
+// listHeight returns how many rows of the listing fit between header and footer
+func (m Model) listHeight() int {
+	height := m.Height - 2 - len(m.footerLines())
+	if m.showsRootInfo() {
+		height--
+	}
+	return height
+}
 
-	return size
+// showsRootInfo reports whether the line with the current directory's metadata is shown
+func (m Model) showsRootInfo() bool {
+	return m.ShowRootInfo && !m.ShowTopDirs && m.RootDir != nil && !m.singleFile()
+}
+
+// rootInfo describes the current directory's mode, owner and modification time
+func (m Model) rootInfo() string {
+	info := []string{m.RootDir.Mode.String()}
+	if m.RootDir.Owner != "" {
+		info = append(info, m.RootDir.Owner)
+	}
+	info = append(info, "modified "+m.RootDir.ModTime.Format("2006-01-02 15:04"))
+	return strings.Join(info, "  ")
+}
+
+// page scrolls the list by a page in direction dir (-1 or 1) and moves the
+// cursor along, so it keeps its row on screen, as in less. Near the ends the
+// list scrolls only as far as it can without blank rows; once it can't
+// scroll any further, the cursor moves to the first or last entry.
+func (m *Model) page(dir int) {
+	if len(m.VisibleDirs) == 0 {
+		return
+	}
+	height := max(m.listHeight(), 1)
+	maxScroll := max(len(m.VisibleDirs)-height, 0)
+	scroll := min(max(m.ScrollPos+dir*height, 0), maxScroll)
+	if scroll == m.ScrollPos {
+		if dir < 0 {
+			m.CursorPos = 0
+		} else {
+			m.CursorPos = len(m.VisibleDirs) - 1
+		}
+	} else {
+		m.CursorPos += scroll - m.ScrollPos
+		m.ScrollPos = scroll
+	}
+	m.ensureCursorVisible()
 }
 
 func (m *Model) ensureCursorVisible() {
+	m.clampCursor()
 	if len(m.VisibleDirs) == 0 {
 		return
 	}
 
 	// Calculate visible window
-	maxVisible := m.Height - 2 // Leave space for header
+	maxVisible := m.listHeight()
 
 	// Adjust scroll position to keep cursor visible
 	if m.CursorPos < m.ScrollPos {
@@ -172,31 +639,179 @@ func (m *Model) ensureCursorVisible() {
 }
 
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if m.frame != nil && m.changesFrame(msg) {
+		m.frame.generation++
+	}
+	model, cmd := m.update(msg)
+	if updated, ok := model.(Model); ok {
+		// Whatever changed the selection, the preview of the new one is read in the background
+		if load := updated.loadPreview(); load != nil {
+			return updated, tea.Batch(cmd, load)
+		}
+		return updated, cmd
+	}
+	return model, cmd
+}
+
+func (m Model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.Height = msg.Height
+		m.Width = msg.Width
 		m.ensureCursorVisible()
 		return m, nil
 
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
+
 	case LoadingMsg:
+		ctx := m.startScan()
+		if msg.Refresh {
+			// Keep showing the current listing instead of the loading screen
+			return m, m.loadDirectory(ctx, msg.Path, true)
+		}
 		m.Loading = true
 		m.LoadingPath = msg.Path
-		return m, m.loadDirectory(msg.Path)
+		m.ScannedCount, m.ScanCurrent = 0, ""
+		return m, tea.Batch(m.loadDirectory(ctx, msg.Path, false), m.doSpinner(), progressCmd(m.progress))
 
 	case LoadingCompleteMsg:
+		if msg.Scan != m.scanID {
+			// Superseded by a scan started since
+			return m, nil
+		}
+		if errors.Is(msg.Error, context.Canceled) {
+			// The user navigated away, stay where we are
+			m.Loading = false
+			m.returning = false
+			return m, nil
+		}
+		if msg.Refresh {
+			return m, tea.Batch(m.applyRefresh(msg), m.refineCmd())
+		}
 		m.Loading = false
 		if msg.Error != nil {
 			m.Error = msg.Error
 		} else {
+			var from string
+			if m.RootDir != nil {
+				from = m.RootDir.Path
+			}
+			if from != "" && !m.returning && from != msg.Dir.Path {
+				m.pushHistory(from)
+			}
+			m.returning = false
 			m.RootDir = msg.Dir
+			m.Quota = msg.Quota
+			m.Disk = msg.Disk
+			m.ScanDuration = msg.Duration
+			m.ScanFileCount, m.ScanDirCount = msg.FileCount, msg.DirCount
+			m.ShowAllIn = nil
+			m.flashBase = nil
+			m.Plan = nil
+			m.Expanded = nil
+			m.Filtering, m.Filter = false, ""
 			m.updateVisibleDirs()
-			// Ensure first entry is always marked after loading
+			// The first entry is marked after loading, unless the directory
+			// left is below the new one
+			m.CursorPos = 0
+			m.ScrollPos = 0
+			m.selectReturnedFrom(from)
+			m.ensureCursorVisible()
+		}
+		return m, m.refineCmd()
+
+	case TopDirsMsg:
+		if msg.Scan != m.scanID {
+			return m, nil
+		}
+		m.Loading = false
+		if errors.Is(msg.Error, context.Canceled) {
+			return m, nil
+		}
+		if msg.Error != nil {
+			m.Error = msg.Error
+		} else {
+			m.ShowTopDirs = true
+			m.TopFiles = msg.Files
+			m.VisibleDirs = msg.Dirs
 			m.CursorPos = 0
 			m.ScrollPos = 0
 			m.ensureCursorVisible()
 		}
 		return m, nil
 
+	case ExportMsg:
+		if msg.Error != nil {
+			m.Status = fmt.Sprintf("Export failed: %v", msg.Error)
+		} else {
+			m.Status = fmt.Sprintf("Exported to %s", msg.Path)
+		}
+		return m, nil
+
+	case ExpandMsg:
+		m.applyExpand(msg)
+		return m, nil
+
+	case CommandDoneMsg:
+		if msg.Error != nil {
+			m.Status = fmt.Sprintf("%s: %v", msg.Command, msg.Error)
+		}
+		if msg.Modifies {
+			// The command may have deleted or changed anything here
+			scanner.Invalidate(m.RootDir.Path)
+			path := m.RootDir.Path
+			return m, func() tea.Msg {
+				return LoadingMsg{Path: path, Refresh: true}
+			}
+		}
+		return m, nil
+
+	case ExecuteFileMsg:
+		if msg.Error != nil {
+			m.Status = fmt.Sprintf("Could not open %s: %v", msg.FilePath, msg.Error)
+		}
+		return m, nil
+
+	case ClipboardMsg:
+		if msg.Error != nil {
+			m.Status = fmt.Sprintf("Copy failed: %v", msg.Error)
+		} else {
+			m.Status = fmt.Sprintf("Copied %s", msg.Text)
+		}
+		return m, nil
+
+	case IgnoreMsg:
+		return m, m.applyIgnore(msg)
+
+	case PreviewMsg:
+		if m.previews != nil {
+			m.previews[msg.Path] = msg.Preview
+		}
+		return m, nil
+
+	case DeleteMsg:
+		return m, m.applyDelete(msg)
+
+	case RestoreMsg:
+		return m, m.applyRestore(msg)
+
+	case SizesRefinedMsg:
+		return m, m.applyRefinedSizes(msg)
+
+	case FlashDoneMsg:
+		if msg.ID == m.flashID {
+			m.Flash = nil
+		}
+		return m, nil
+
+	case ScanProgressMsg:
+		if !m.Loading || msg.progress != m.progress {
+			return m, nil
+		}
+		m.ScannedCount, m.ScanCurrent = msg.Count, msg.Current
+		return m, progressCmd(m.progress)
+
 	case SpinnerMsg:
 		if m.Loading {
 			m.SpinnerIdx = (m.SpinnerIdx + 1) % len(spinnerFrames)
@@ -206,12 +821,62 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 	case tea.KeyMsg:
 		if m.Loading {
-			if msg.String() == "q" || msg.String() == "ctrl+c" {
+			switch msg.String() {
+			case "q", "ctrl+c":
 				return m, tea.Quit
+			case "esc", "backspace", "h":
+				// Abandon the scan and stay in the current directory
+				m.cancelScan()
+				m.Loading = false
+				m.returning = false
+				m.Status = "Scan cancelled"
 			}
 			return m, nil
 		}
 
+		m.Status = ""
+
+		if m.Input != nil {
+			return m.updateInput(msg)
+		}
+
+		if m.Confirm != nil {
+			// Anything other than an explicit yes cancels
+			confirm := m.Confirm
+			m.Confirm = nil
+			if msg.String() == "y" || msg.String() == "Y" {
+				return m, confirm.OnYes
+			}
+			return m, nil
+		}
+
+		if m.ShowErrors {
+			return m.updateErrorPane(msg)
+		}
+		if m.ShowIgnored {
+			return m.updateIgnorePane(msg)
+		}
+		if m.Filtering {
+			if model, cmd, handled := m.updateFilter(msg); handled {
+				return model, cmd
+			}
+		}
+
+		if m.PendingKey == "z" {
+			// Second key of zz/zt/zb: align the selected row in the viewport
+			m.PendingKey = ""
+			switch msg.String() {
+			case "z":
+				m.ScrollPos = m.CursorPos - m.listHeight()/2
+			case "t":
+				m.ScrollPos = m.CursorPos
+			case "b":
+				m.ScrollPos = m.CursorPos - m.listHeight() + 1
+			}
+			m.ensureCursorVisible()
+			return m, nil
+		}
+
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
@@ -227,10 +892,19 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 
 		case "enter":
-			if m.CursorPos < len(m.VisibleDirs) {
+			if m.CursorPos >= 0 && m.CursorPos < len(m.VisibleDirs) {
 				dir := m.VisibleDirs[m.CursorPos]
-				if dir.IsDir {
-					if dir.Name == ".." {
+				if dir.Summary {
+					// List the remaining children in place of the summary row
+					if m.ShowAllIn == nil {
+						m.ShowAllIn = make(map[string]bool)
+					}
+					m.ShowAllIn[dir.ParentDir.Path] = true
+					m.rebuildVisibleDirs()
+				} else if dir.IsDir && m.TreeMode && !m.ShowTopDirs && !isParentEntry(dir) {
+					return m, m.toggleExpanded(dir)
+				} else if dir.IsDir {
+					if isParentEntry(dir) {
 						parentPath := filepath.Dir(m.RootDir.Path)
 						if parentPath != m.RootDir.Path {
 							return m, func() tea.Msg {
@@ -242,11 +916,24 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 							return LoadingMsg{Path: dir.Path}
 						}
 					}
+				} else if isExecutable(dir.Path) {
+					// Running arbitrary programs requires explicit confirmation
+					m.Confirm = &Confirmation{
+						Prompt: fmt.Sprintf("Execute %s? [y/N]", dir.Path),
+						OnYes:  m.executeFile(dir.Path),
+					}
 				} else {
-					// Execute file
+					// Open file with the default application
 					return m, m.executeFile(dir.Path)
 				}
 			}
+		case "right", "l":
+			// Always enter the directory, even when enter expands it inline
+			if dir := m.selectedEntry(); dir != nil && dir.IsDir && !dir.Summary {
+				return m, func() tea.Msg {
+					return LoadingMsg{Path: dir.Path}
+				}
+			}
 		case "backspace", "h":
 			parentPath := filepath.Dir(m.RootDir.Path)
 			if parentPath != m.RootDir.Path {
@@ -262,58 +949,395 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.CursorPos = len(m.VisibleDirs) - 1
 				m.ensureCursorVisible()
 			}
-		case "pgup":
-			maxVisible := m.Height - 2
-			m.CursorPos -= maxVisible
-			if m.CursorPos < 0 {
-				m.CursorPos = 0
+		case "}":
+			// Skip past the current entry's descendants to its next sibling
+			if m.CursorPos < len(m.VisibleDirs) {
+				level := m.VisibleDirs[m.CursorPos].Level
+				for i := m.CursorPos + 1; i < len(m.VisibleDirs); i++ {
+					if m.VisibleDirs[i].Level <= level {
+						m.CursorPos = i
+						m.ensureCursorVisible()
+						break
+					}
+				}
+			}
+		case "{":
+			// Jump back to the previous sibling (or the parent when there is none)
+			if m.CursorPos < len(m.VisibleDirs) {
+				level := m.VisibleDirs[m.CursorPos].Level
+				for i := m.CursorPos - 1; i >= 0; i-- {
+					if m.VisibleDirs[i].Level <= level {
+						m.CursorPos = i
+						m.ensureCursorVisible()
+						break
+					}
+				}
+			}
+		case "!":
+			if entry := m.selectedEntry(); entry != nil && !isParentEntry(entry) && !entry.Summary {
+				m.promptCommand(entry)
+			}
+		case "C":
+			return m, m.exportCSVCmd()
+		case "s":
+			return m, m.exportJSONCmd()
+		case "I":
+			if entry := m.selectedEntry(); entry != nil && !isParentEntry(entry) && !entry.Summary {
+				path := entry.Path
+				m.Confirm = &Confirmation{
+					Prompt: fmt.Sprintf("Ignore %s in all future scans? [y/N]", path),
+					OnYes:  m.ignoreCmd(path, true),
+				}
+			}
+		case "U":
+			m.ShowIgnored = true
+			m.IgnoreList = scanner.Ignored()
+			m.IgnoreCursor = 0
+		case "y":
+			if entry := m.selectedEntry(); entry != nil && !entry.Summary {
+				return m, copyCmd(entry.Path)
+			}
+		case "Y":
+			if entry := m.selectedEntry(); entry != nil && !isParentEntry(entry) {
+				return m, copyCmd(sizeText(entry.Size))
+			}
+		case "z":
+			m.PendingKey = "z"
+		case "#":
+			m.QuickSelect = !m.QuickSelect
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			if m.QuickSelect {
+				idx := int(msg.String()[0]-'1') + m.quickSelectOffset()
+				if idx < len(m.VisibleDirs) {
+					m.CursorPos = idx
+					m.ensureCursorVisible()
+				}
+			}
+		case "p":
+			m.ShowPreview = !m.ShowPreview
+		case "L":
+			// Select the single largest entry, skipping the ".." pseudo-entry
+			largest := -1
+			for i, dir := range m.VisibleDirs {
+				if isParentEntry(dir) || dir.Summary {
+					continue
+				}
+				if largest < 0 || dir.Size > m.VisibleDirs[largest].Size {
+					largest = i
+				}
+			}
+			if largest >= 0 {
+				m.CursorPos = largest
+				m.ensureCursorVisible()
 			}
+		case "%":
+			m.RootRelative = !m.RootRelative
+		case "P":
+			m.PercentDecimals = (m.PercentDecimals + 1) % (maxPercentDecimals + 1)
+		case "b":
+			m.ShowBar = !m.ShowBar
+		case "c":
+			m.ShowCounts = !m.ShowCounts
+		case "n":
+			m.cycleItemCounts()
+		case "[":
+			return m, m.historyBack()
+		case ".":
+			return m, m.toggleHidden()
+		case "m":
+			m.cycleMinSize()
+		case " ":
+			return m, m.toggleInline()
+		case "O":
+			return m, m.openDirCmd()
+		case "/":
+			if !m.ShowTopDirs {
+				m.startFilter()
+			}
+		case "r":
+			if m.RootDir != nil && !m.ShowTopDirs {
+				// Changes deep down don't show in the cached directories' mtimes
+				scanner.Invalidate(m.RootDir.Path)
+				m.Status = "Refreshing " + m.RootDir.Path
+				path := m.RootDir.Path
+				return m, func() tea.Msg {
+					return LoadingMsg{Path: path, Refresh: true}
+				}
+			}
+		case "A":
+			m.ShowAllocated = !m.ShowAllocated
+		case "B":
+			return m, m.toggleApparentSize()
+		case "a":
+			return m, m.toggleShowFiles()
+		case "d":
+			m.confirmDelete()
+		case "u":
+			return m, m.undoTrash()
+		case "S":
+			m.promptSaveSession()
+		case "N":
+			m.ShowFullName = !m.ShowFullName
 			m.ensureCursorVisible()
-		case "pgdown":
-			maxVisible := m.Height - 2
-			m.CursorPos += maxVisible
-			if m.CursorPos >= len(m.VisibleDirs) {
-				m.CursorPos = len(m.VisibleDirs) - 1
+		case "F":
+			if m.ShowTopDirs {
+				break
+			}
+			if m.Plan != nil {
+				m.Plan = nil
+				m.ensureCursorVisible()
+				break
 			}
+			m.promptFreeSpace()
+		case "x":
+			if m.Plan != nil {
+				m.togglePlanned()
+			}
+		case "D":
+			if m.Plan != nil {
+				m.confirmPlan()
+			}
+		case "i":
+			m.ShowRootInfo = !m.ShowRootInfo
 			m.ensureCursorVisible()
+		case "o":
+			if !m.ShowTopDirs {
+				m.SortMode = (m.SortMode + 1) % SortMode(len(sortModeNames))
+				selected := m.selectedEntry()
+				m.rebuildVisibleDirs()
+				if selected != nil && m.selectPath(selected.Path) {
+					m.ensureCursorVisible()
+				}
+				m.Status = "Sorted by " + sortModeNames[m.SortMode]
+			}
+		case "~":
+			m.HomeRelative = !m.HomeRelative
+		case "t":
+			if !m.ShowTopDirs {
+				m.toggleTreeMode()
+			}
+		case "E":
+			m.ShowErrors = true
+			m.ScanErrors = scanner.ErrorsUnder(m.RootDir.Path)
+			m.ErrorScroll = 0
+		case "T":
+			if m.ShowTopDirs && !m.TopFiles {
+				m.updateVisibleDirs()
+				return m, nil
+			}
+			m.Loading = true
+			m.LoadingPath = m.RootDir.Path
+			ctx := m.startScan()
+			return m, tea.Batch(m.loadTopDirs(ctx, m.RootDir.Path, m.RootDir.Size, m.TopDirsN), m.doSpinner())
+		case "f":
+			if m.ShowTopDirs && m.TopFiles {
+				m.updateVisibleDirs()
+				return m, nil
+			}
+			m.Loading = true
+			m.LoadingPath = m.RootDir.Path
+			ctx := m.startScan()
+			return m, tea.Batch(m.loadTopFiles(ctx, m.RootDir.Path, m.RootDir.Size, m.TopDirsN), m.doSpinner())
+		case "pgup":
+			m.page(-1)
+		case "pgdown":
+			m.page(1)
+		}
+	}
+	return m, nil
+}
+
+// updateInput edits the pending InputPrompt
+func (m Model) updateInput(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.Type {
+	case tea.KeyCtrlC:
+		return m, tea.Quit
+	case tea.KeyEsc:
+		m.Input = nil
+	case tea.KeyEnter:
+		input := m.Input
+		m.Input = nil
+		return m, input.OnSubmit(&m, input.Value)
+	case tea.KeyBackspace:
+		if runes := []rune(m.Input.Value); len(runes) > 0 {
+			m.Input.Value = string(runes[:len(runes)-1])
 		}
+	case tea.KeySpace:
+		m.Input.Value += " "
+	case tea.KeyRunes:
+		m.Input.Value += string(msg.Runes)
+	}
+	return m, nil
+}
+
+// updateErrorPane handles keys while the scan error pane is open
+func (m Model) updateErrorPane(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	maxVisible := m.Height - 2
+	maxScroll := len(m.ScanErrors) - maxVisible
+	if maxScroll < 0 {
+		maxScroll = 0
+	}
+
+	switch msg.String() {
+	case "q", "ctrl+c":
+		return m, tea.Quit
+	case "E", "esc":
+		m.ShowErrors = false
+	case "up", "k":
+		m.ErrorScroll--
+	case "down", "j":
+		m.ErrorScroll++
+	case "pgup":
+		m.ErrorScroll -= maxVisible
+	case "pgdown":
+		m.ErrorScroll += maxVisible
+	case "home", "g":
+		m.ErrorScroll = 0
+	case "end", "G":
+		m.ErrorScroll = maxScroll
+	}
+
+	if m.ErrorScroll > maxScroll {
+		m.ErrorScroll = maxScroll
+	}
+	if m.ErrorScroll < 0 {
+		m.ErrorScroll = 0
 	}
 	return m, nil
 }
 
-func (m Model) View() string {
+// errorPaneView renders the list of scan errors below the current root
+func (m Model) errorPaneView(headerStyle lipgloss.Style) string {
+	var s strings.Builder
+
+	header := fmt.Sprintf("%s scan errors under %s (E/esc to close)", m.formatCount(int64(len(m.ScanErrors))), m.headerPath())
+	s.WriteString(headerStyle.Render(header) + "\n")
+	if m.showsRootInfo() {
+		s.WriteString(m.theme().Muted.Render(m.rootInfo()) + "\n")
+	}
+
+	if len(m.ScanErrors) == 0 {
+		s.WriteString("  No errors recorded\n")
+		return s.String()
+	}
+
+	pathStyle := m.theme().File
+	errStyle := m.theme().Error
+
+	end := m.ErrorScroll + m.Height - 2
+	if end > len(m.ScanErrors) {
+		end = len(m.ScanErrors)
+	}
+	for _, scanErr := range m.ScanErrors[m.ErrorScroll:end] {
+		// Strip the path from *PathError messages, it's already shown in its own column
+		message := scanErr.Err.Error()
+		var pathErr *fs.PathError
+		if errors.As(scanErr.Err, &pathErr) {
+			message = pathErr.Err.Error()
+		}
+		s.WriteString("  " + pathStyle.Render(scanErr.Path) + ": " + errStyle.Render(message) + "\n")
+	}
+
+	return s.String()
+}
+
+// render builds the frame for View
+func (m Model) render() string {
 	if m.Error != nil {
 		return fmt.Sprintf("Error: %v", m.Error)
 	}
 
 	if m.Loading {
 		spinner := spinnerFrames[m.SpinnerIdx]
-		return fmt.Sprintf("%s Loading %s...", spinner, m.LoadingPath)
+		loading := fmt.Sprintf("%s Loading %s...", spinner, m.displayPath(m.LoadingPath))
+		if m.ScannedCount > 0 {
+			loading += fmt.Sprintf(" (%s items)", m.formatCount(m.ScannedCount))
+		}
+		loading += " (esc to cancel)"
+		if m.ScanCurrent != "" {
+			loading += "\n" + m.theme().Muted.Render(wrapRunes(m.displayPath(m.ScanCurrent), m.Width)[0])
+		}
+		return loading
 	}
 
 	var s strings.Builder
 
 	// Header with current path
-	headerStyle := lipgloss.NewStyle().
-		Foreground(lipgloss.Color("226")). // Bright yellow text
-		Background(lipgloss.Color("235")). // Dark gray background
-		AlignHorizontal(lipgloss.Right)
-	s.WriteString(headerStyle.Render(fmt.Sprint(m.RootDir.Path)) + "\n")
-
-	selectedStyle := lipgloss.NewStyle().Background(lipgloss.Color("240"))
-	dirStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("39")).Bold(true)
-	fileStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("252"))
-	sizeStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("248"))
-	percentStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	theme := m.theme()
+	headerStyle := theme.Header
+
+	if m.ShowErrors {
+		return m.errorPaneView(headerStyle)
+	}
+	if m.ShowIgnored {
+		return m.ignorePaneView(headerStyle)
+	}
+	header := breadcrumbs(m.headerPath())
+	if m.singleFile() {
+		header = "File: " + header
+	} else if m.ShowTopDirs && m.TopFiles {
+		header = fmt.Sprintf("Largest %d files under %s", len(m.VisibleDirs), header)
+	} else if m.ShowTopDirs {
+		header = fmt.Sprintf("Largest %d directories under %s", len(m.VisibleDirs), header)
+	} else if share := m.startShare(); share != "" {
+		header += "  (" + share + ")"
+	}
+	if m.ShowAllocated {
+		header += "  [size | apparent | allocated | allocated/apparent]"
+	}
+	if m.ItemCounts == ItemCountsRecursive {
+		header += "  [items: all below]"
+	}
+	if m.MinSize > 0 && !m.ShowTopDirs && !m.singleFile() {
+		header += "  min: " + humanize.Bytes(uint64(m.MinSize))
+	}
+	if m.Filtering || m.Filter != "" {
+		header += "  filter: " + m.Filter
+		if m.Filtering {
+			header += "_"
+		}
+	}
+	if !m.RootRelative && m.TreeMode && len(m.Expanded) > 0 {
+		// Nested rows are on a different scale than their parents
+		header += "  [% of parent]"
+	}
+	if m.Disk != nil {
+		// Right-aligned when there is room for it
+		disk := fmt.Sprintf("disk: %s used of %s, %s free", humanize.Bytes(uint64(m.Disk.Used)),
+			humanize.Bytes(uint64(m.Disk.Total)), humanize.Bytes(uint64(m.Disk.Free)))
+		gap := m.Width - lipgloss.Width(header) - lipgloss.Width(disk)
+		header += strings.Repeat(" ", max(gap, 2)) + disk
+	}
+	s.WriteString(headerStyle.Render(header) + "\n")
+	if m.showsRootInfo() {
+		s.WriteString(theme.Muted.Render(m.rootInfo()) + "\n")
+	}
+
+	fileStyle := theme.File
+	sizeStyle := theme.Size
+	percentStyle := theme.Percent
+	parentStyle := theme.Muted
+	flashStyle := theme.Flash
 
 	// Calculate visible window
-	maxVisible := m.Height - 2 // Leave space for header
+	maxVisible := m.listHeight()
 	start := m.ScrollPos
 	end := start + maxVisible
 	if end > len(m.VisibleDirs) {
 		end = len(m.VisibleDirs)
 	}
 
+	// Rows are collected separately so the preview pane can be placed beside them
+	var list strings.Builder
+
+	if m.singleFile() {
+		// A file has no rows to list, its details take their place
+		list.WriteString(m.filePanel())
+		end = start
+	} else if len(m.VisibleDirs) == 0 {
+		list.WriteString(fileStyle.Render("  (empty)") + "\n")
+	}
+
 	// Show only visible entries
 	for i := start; i < end; i++ {
 		dir := m.VisibleDirs[i]
@@ -323,49 +1347,367 @@ func (m Model) View() string {
 
 		// Add prefix for directory/file type
 		var prefix string
-		if dir.IsDir {
+		if dir.MountPoint {
+			prefix = "⊗ "
+		} else if dir.ReadError != nil {
+			prefix = "⚠ "
+		} else if dir.IsSymlink {
+			prefix = "→ "
+		} else if dir.IsDir && m.TreeMode && m.Expanded[dir.Path] && dir.Children != nil {
+			prefix = "▼ "
+		} else if dir.IsDir {
 			prefix = "▶ "
 		} else {
 			prefix = "· "
 		}
+		if m.Plan[dir.Path] {
+			prefix = "✗ "
+		}
 
-		name := dir.Name
-		if len(name) > 50 {
-			name = name[:47] + "..."
+		// Number the first nine entries while quick-select is active
+		if m.QuickSelect {
+			if n := i - m.quickSelectOffset() + 1; n >= 1 && n <= 9 {
+				indent = fmt.Sprintf("%d ", n) + indent
+			} else {
+				indent = "  " + indent
+			}
 		}
 
-		if dir.IsDir {
-			name = dirStyle.Render(name + "/")
+		// Notes after the name, dropped when they would leave it too little room
+		var notes string
+		if dir.FSType != "" {
+			notes += " [" + dir.FSType + "]"
+		}
+		if dir.Refining {
+			notes += " …"
+		}
+		if dir.Truncated {
+			notes += " (+)"
+		}
+		if m.ShowCounts && dir.IsDir && !isParentEntry(dir) {
+			notes += fmt.Sprintf(" (%s dirs, %s files)", m.formatCount(dir.ChildDirs), m.formatCount(dir.ChildFiles))
+		}
+		slash := ""
+		if dir.IsDir && !dir.Summary {
+			slash = "/"
+		}
+		nameWidth := m.nameWidth(lipgloss.Width(indent + prefix))
+		room := nameWidth - len(slash) - lipgloss.Width(notes)
+		if room < minNameWidth {
+			notes = ""
+			room = nameWidth - len(slash)
+		}
+		name := ellipsizeMiddle(dir.Name, room)
+
+		nameStyle, entrySizeStyle := theme.entryStyles(dir)
+		if isParentEntry(dir) {
+			name = parentStyle.Render(name + slash)
+		} else if dir.Summary {
+			prefix = "  "
+			name = parentStyle.Render(name)
 		} else {
-			name = fileStyle.Render(name)
+			name = nameStyle.Render(name + slash)
+		}
+		if notes != "" {
+			name += parentStyle.Render(notes)
+		}
+		if pad := nameWidth - lipgloss.Width(name); pad > 0 {
+			name += strings.Repeat(" ", pad)
 		}
 
-		size := sizeStyle.Render(fmt.Sprintf("%10s", humanize.Bytes(uint64(dir.Size))))
-		percent := percentStyle.Render(fmt.Sprintf("%7.1f%%", dir.Percent))
+		size := entrySizeStyle.Render(formatSize(dir.Size, m.PlainSizes))
+		if dir.ReadError != nil {
+			size = sizeStyle.Render(fmt.Sprintf("%10s", "?"))
+		}
+		percent := percentStyle.Render(m.formatPercent(m.displayPercent(dir)))
+		if isParentEntry(dir) {
+			// Size and percent are meaningless for the parent link
+			size = strings.Repeat(" ", 10)
+			percent = strings.Repeat(" ", m.percentWidth()+1)
+		}
+		if m.ItemCounts != ItemCountsHidden {
+			size += " " + sizeStyle.Render(m.itemCount(dir))
+		}
+		if m.ShowAllocated {
+			column := strings.Repeat(" ", allocationWidth)
+			if !isParentEntry(dir) {
+				column = allocation(dir, m.PlainSizes)
+			}
+			size += " " + sizeStyle.Render(column)
+		}
+		if m.ShowBar {
+			bar := strings.Repeat(" ", m.barWidth())
+			if !isParentEntry(dir) {
+				bar = m.usageBar(dir)
+			}
+			size += " " + bar
+		}
 
 		// Build the line with proper indentation and column alignment
 		var line string
 		if i == m.CursorPos {
 			// For selected line, add selection indicator but maintain column alignment
-			line = fmt.Sprintf("> %s%s%-70s%s%s", indent, prefix, name, size, percent)
-			line = selectedStyle.Render(line)
+			line = fmt.Sprintf("> %s%s%s%s%s", indent, prefix, name, size, percent)
+			line = theme.selectRow(line)
 		} else {
 			// For non-selected lines, add 2 spaces to match the "> " width
-			line = fmt.Sprintf("  %s%s%-70s%s%s", indent, prefix, name, size, percent)
+			line = fmt.Sprintf("  %s%s%s%s%s", indent, prefix, name, size, percent)
+			if m.Flash[dir.Path] {
+				line = flashStyle.Render(line)
+			}
 		}
 
-		s.WriteString(line + "\n")
+		list.WriteString(line + "\n")
+	}
+
+	if m.ShowPreview && m.Width > 0 {
+		listWidth := m.listWidth()
+		rows := lipgloss.NewStyle().MaxWidth(listWidth).Render(strings.TrimSuffix(list.String(), "\n"))
+		s.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, rows, m.previewView(m.Width-listWidth, maxVisible)) + "\n")
+	} else {
+		s.WriteString(list.String())
+	}
+
+	footerStyle := theme.Muted
+	for _, line := range m.footerLines() {
+		s.WriteString(footerStyle.Render(line) + "\n")
+	}
+
+	promptStyle := theme.Prompt
+	switch {
+	case m.Confirm != nil:
+		s.WriteString(promptStyle.Render(m.Confirm.Prompt))
+	case m.Input != nil:
+		s.WriteString(promptStyle.Render(m.Input.Prompt) + m.Input.Value + "█")
+	case m.Status != "":
+		s.WriteString(footerStyle.Render(m.Status))
 	}
 
 	return s.String()
 }
 
+// minNameWidth is the narrowest the name column gets on small terminals,
+// where the rows may then be wider than the terminal
+const minNameWidth = 8
+
+// nameWidth returns the width of the name column for rows whose indentation
+// and prefix take lead cells, so the columns after it line up at the right
+// edge of the list. Without a known terminal width the columns end after 70
+// cells of name on the top level.
+func (m Model) nameWidth(lead int) int {
+	if m.Width <= 0 {
+		return max(74-lead, minNameWidth)
+	}
+	width := m.listWidth() - 2 - lead - m.columnsWidth()
+	if width < minNameWidth {
+		return minNameWidth
+	}
+	return width
+}
+
+// listWidth returns the width of the list, which shares the terminal with the preview
+func (m Model) listWidth() int {
+	if m.ShowPreview {
+		return m.Width / 2
+	}
+	return m.Width
+}
+
+// columnsWidth returns the width of the columns after the name
+func (m Model) columnsWidth() int {
+	width := 10 + m.percentWidth() + 1
+	if m.ItemCounts != ItemCountsHidden {
+		width += 1 + itemsWidth
+	}
+	if m.ShowAllocated {
+		width += 1 + allocationWidth
+	}
+	if m.ShowBar {
+		width += 1 + m.barWidth()
+	}
+	return width
+}
+
+// ellipsizeMiddle shortens s to width runes by replacing its middle with
+// "…", so both the start of a long name and its extension stay visible
+func ellipsizeMiddle(s string, width int) string {
+	runes := []rune(s)
+	if len(runes) <= width {
+		return s
+	}
+	if width <= 1 {
+		return "…"
+	}
+	tail := (width - 1) / 2
+	head := width - 1 - tail
+	return string(runes[:head]) + "…" + string(runes[len(runes)-tail:])
+}
+
+// maxPercentDecimals is the most decimals the percent column can show
+const maxPercentDecimals = 2
+
+// percentWidth returns the width of the percent column without the % sign:
+// room for "100" and the decimals, plus two spaces separating it from the size
+func (m Model) percentWidth() int {
+	if m.PercentDecimals == 0 {
+		return 5
+	}
+	return 6 + m.PercentDecimals
+}
+
+// formatPercent formats percent for the percent column with PercentDecimals decimals
+func (m Model) formatPercent(percent float64) string {
+	return fmt.Sprintf("%*.*f%%", m.percentWidth(), m.PercentDecimals, percent)
+}
+
+// allocationWidth is the width of the columns added by ShowAllocated
+const allocationWidth = 10 + 1 + 10 + 8
+
+// allocation formats entry's apparent and allocated size and their ratio.
+// Sparse and compressed files show a ratio below 1, lots of small files
+// wasting the rest of their blocks one above it.
+func allocation(entry *scan.DirEntry, plain bool) string {
+	ratio := "       -"
+	if entry.Apparent > 0 {
+		r := float64(entry.Allocated) / float64(entry.Apparent)
+		if r < 1000 {
+			ratio = fmt.Sprintf(" %6.2f×", r)
+		} else {
+			ratio = fmt.Sprintf(" %6.0f×", r)
+		}
+	}
+	return formatSize(entry.Apparent, plain) + " " + formatSize(entry.Allocated, plain) + ratio
+}
+
+// barEighths are the characters filling the last cell of the usage bar, by eighths
+var barEighths = []string{"", "▏", "▎", "▍", "▌", "▋", "▊", "▉"}
+
+// barWidth returns the number of cells in the usage bar, a sixth of the list
+// width but at least 10 and at most 40, or 20 without a known terminal width
+func (m Model) barWidth() int {
+	if m.Width <= 0 {
+		return 20
+	}
+	return min(max(m.listWidth()/6, 10), 40)
+}
+
+// usageBar draws entry's percentage as a bar in two segments: the files
+// directly inside it and the rest, which is nested in its subdirectories.
+// The bar ends in a partial cell, so small differences still show.
+func (m Model) usageBar(entry *scan.DirEntry) string {
+	width := m.barWidth()
+	eighths := min(int(m.displayPercent(entry)/100*float64(width*8)+0.5), width*8)
+	full, partial := eighths/8, barEighths[eighths%8]
+	own := full
+	if entry.Size > 0 {
+		own = int(float64(full)*float64(entry.OwnSize)/float64(entry.Size) + 0.5)
+	}
+	padding := width - full
+	if partial != "" {
+		padding--
+	}
+
+	ownStyle := m.theme().BarOwn
+	nestedStyle := m.theme().BarNested
+	partialStyle := nestedStyle
+	if entry.OwnSize == entry.Size {
+		partialStyle = ownStyle
+	}
+	return ownStyle.Render(strings.Repeat("█", own)) +
+		nestedStyle.Render(strings.Repeat("█", full-own)) +
+		partialStyle.Render(partial) +
+		strings.Repeat(" ", padding)
+}
+
+// selectedEntry returns the entry under the cursor, or nil when the list is empty
+func (m Model) selectedEntry() *scan.DirEntry {
+	if m.CursorPos < 0 || m.CursorPos >= len(m.VisibleDirs) {
+		return nil
+	}
+	return m.VisibleDirs[m.CursorPos]
+}
+
+// isParentEntry reports whether entry is the ".." link to the parent directory,
+// which only supports navigation and is never acted on
+func isParentEntry(entry *scan.DirEntry) bool {
+	return entry.Name == ".." && entry.Level == 0
+}
+
+// quickSelectOffset returns the index of the entry bound to digit 1, skipping ".."
+func (m Model) quickSelectOffset() int {
+	if len(m.VisibleDirs) > 0 && isParentEntry(m.VisibleDirs[0]) {
+		return 1
+	}
+	return 0
+}
+
+// displayPercent returns the entry's percentage relative to RootDir when
+// RootRelative is set (the default), so rows expanded inline share one scale
+// with their parents, or else relative to the entry's own parent
+func (m Model) displayPercent(entry *scan.DirEntry) float64 {
+	if !m.RootRelative || m.ShowTopDirs {
+		return entry.Percent
+	}
+	if m.RootDir.Size <= 0 {
+		return 0
+	}
+	return float64(entry.Size) / float64(m.RootDir.Size) * 100
+}
+
+// headerPath returns the current path for the header, with the start path replaced by Label when set
+func (m Model) headerPath() string {
+	if m.Label == "" || !scan.IsWithin(m.RootDir.Path, m.StartPath) {
+		// Above the start path the label no longer applies
+		return m.displayPath(m.RootDir.Path)
+	}
+	rel, _ := filepath.Rel(m.StartPath, m.RootDir.Path)
+	if rel == "." {
+		return m.Label
+	}
+	return m.Label + string(filepath.Separator) + rel
+}
+
+// displayPath shortens paths inside the home directory to ~/... when HomeRelative is set
+func (m Model) displayPath(path string) string {
+	if !m.HomeRelative || m.Home == "" || !scan.IsWithin(path, m.Home) {
+		return path
+	}
+	rel, _ := filepath.Rel(m.Home, path)
+	if rel == "." {
+		return "~"
+	}
+	return "~" + string(filepath.Separator) + rel
+}
+
+// startShare describes the current directory's share of the start path's
+// total, or returns "" at the start path and outside of it
+func (m Model) startShare() string {
+	if m.StartSize <= 0 || m.RootDir.Path == m.StartPath || !scan.IsWithin(m.RootDir.Path, m.StartPath) {
+		return ""
+	}
+	start := m.displayPath(m.StartPath)
+	if m.Label != "" {
+		start = m.Label
+	}
+	return fmt.Sprintf("%.1f%% of %s", float64(m.RootDir.Size)/float64(m.StartSize)*100, start)
+}
+
 func (m *Model) updateVisibleDirs() {
-	m.VisibleDirs = []*DirEntry{}
+	m.VisibleDirs = []*scan.DirEntry{}
+	m.ShowTopDirs = false
+	m.TopFiles = false
+
+	if m.singleFile() {
+		// The file is the only entry, so enter acts on it
+		m.VisibleDirs = append(m.VisibleDirs, m.RootDir)
+		m.CursorPos = 0
+		m.ScrollPos = 0
+		return
+	}
 
 	parentPath := filepath.Dir(m.RootDir.Path)
 	if parentPath != m.RootDir.Path {
-		parentEntry := &DirEntry{
+		parentEntry := &scan.DirEntry{
 			Name:  "..",
 			Path:  parentPath,
 			IsDir: true,
@@ -374,111 +1716,187 @@ func (m *Model) updateVisibleDirs() {
 		m.VisibleDirs = append(m.VisibleDirs, parentEntry)
 	}
 
-	for _, child := range m.RootDir.Children {
-		if child.IsDir || m.ShowFiles {
-			m.VisibleDirs = append(m.VisibleDirs, child)
-		}
-	}
+	m.appendChildren(m.RootDir)
+	m.applyFilter()
 
 	m.CursorPos = 0
 	m.ScrollPos = 0
 }
 
-// scanDirectoryWithCache scans directory using cached sizes when possible
-func scanDirectoryWithCache(path string, parentDir *DirEntry, level int, showFiles bool) (*DirEntry, error) {
-	info, err := os.Stat(path)
-	if err != nil {
-		return nil, err
+// toggleShowFiles shows or hides files while keeping the selected entry under
+// the cursor. A selected file that is hidden gives way to the closest entry
+// above it that is still listed. Files are only collected by scans that show
+// them, so showing them re-scans the directory in the background.
+func (m *Model) toggleShowFiles() tea.Cmd {
+	m.ShowFiles = !m.ShowFiles
+	if m.ShowTopDirs {
+		return nil
 	}
 
-	entry := &DirEntry{
-		Name:      filepath.Base(path),
-		Path:      path,
-		IsDir:     info.IsDir(),
-		Level:     level,
-		ParentDir: parentDir,
+	old, cursor, scroll := m.VisibleDirs, m.CursorPos, m.ScrollPos
+	m.updateVisibleDirs()
+	m.ScrollPos = scroll
+	for i := cursor; i >= 0 && i < len(old); i-- {
+		if m.selectPath(old[i].Path) {
+			break
+		}
 	}
+	m.ensureCursorVisible()
 
-	if !info.IsDir() {
-		entry.Size = info.Size()
-		return entry, nil
+	if !m.ShowFiles {
+		return nil
 	}
-
-	// For directories, scan immediate children only
-	entries, err := os.ReadDir(path)
-	if err != nil {
-		return nil, err
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
 	}
+}
 
-	var totalSize int64
-	var directories []*DirEntry
-	var files []*DirEntry
+// toggleHidden includes or leaves out hidden entries. Every cached total was
+// summed the other way, so the cache is dropped and the current directory
+// scanned again.
+func (m *Model) toggleHidden() tea.Cmd {
+	show := !scanner.ShowHidden()
+	scanner.SetShowHidden(show)
+	if show {
+		m.Status = "Including hidden entries"
+	} else {
+		m.Status = "Leaving out hidden entries"
+	}
+	m.cancelScan()
+	scanner.ClearCache()
+	if m.RootDir == nil {
+		return nil
+	}
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
+	}
+}
 
-	for _, e := range entries {
-		childPath := filepath.Join(path, e.Name())
+// appendChildren adds the listed children of dir to VisibleDirs, followed by
+// the children of those expanded inline
+func (m *Model) appendChildren(dir *scan.DirEntry) {
+	sortEntries(dir.Children, m.SortMode)
 
-		if strings.HasPrefix(e.Name(), ".") {
-			continue
+	var children []*scan.DirEntry
+	for _, child := range dir.Children {
+		if (child.IsDir || m.ShowFiles) && child.Size >= m.MinSize {
+			children = append(children, child)
 		}
+	}
 
-		childInfo, err := e.Info()
-		if err != nil {
-			continue
+	// A filter looks at every entry, not just the first MaxChildren
+	if m.MaxChildren > 0 && !m.ShowAllIn[dir.Path] && m.Filter == "" && len(children) > m.MaxChildren {
+		// Roll the tail into one row so huge directories stay fast to render
+		rest := children[m.MaxChildren:]
+		summary := &scan.DirEntry{
+			Name:      fmt.Sprintf("(%s more items)", m.formatCount(int64(len(rest)))),
+			Level:     dir.Level + 1,
+			ParentDir: dir,
+			Summary:   true,
 		}
+		for _, child := range rest {
+			summary.Size += child.Size
+			summary.OwnSize += child.Size
+			summary.Apparent += child.Apparent
+			summary.Allocated += child.Allocated
+			summary.Percent += child.Percent
+			summary.FileCount += child.FileCount
+			summary.DirCount += child.DirCount
+		}
+		children = append(children[:m.MaxChildren:m.MaxChildren], summary)
+	}
 
-		if childInfo.IsDir() {
-			// Use cached size (calculated with full recursion when first needed)
-			childSize := getCachedSize(childPath)
-
-			child := &DirEntry{
-				Name:      e.Name(),
-				Path:      childPath,
-				Size:      childSize,
-				IsDir:     true,
-				Level:     level + 1,
-				ParentDir: entry,
-			}
-			directories = append(directories, child)
-			totalSize += childSize
-		} else if showFiles {
-			child := &DirEntry{
-				Name:      e.Name(),
-				Path:      childPath,
-				Size:      childInfo.Size(),
-				IsDir:     false,
-				Level:     level + 1,
-				ParentDir: entry,
-			}
-			files = append(files, child)
-			totalSize += childInfo.Size()
-		} else {
-			totalSize += childInfo.Size()
+	for _, child := range children {
+		m.VisibleDirs = append(m.VisibleDirs, child)
+		if child.IsDir && m.TreeMode && m.Expanded[child.Path] {
+			m.appendChildren(child)
 		}
 	}
+}
 
-	// Sort directories by size (descending)
-	sort.Slice(directories, func(i, j int) bool {
-		return directories[i].Size > directories[j].Size
-	})
+// topExtensions summarizes the n extensions taking the most space directly
+// inside dir, e.g. ".mp4 60% · .jpg 20% · .txt 5%"
+func topExtensions(dir *scan.DirEntry, n int) string {
+	if dir.Size <= 0 || len(dir.ExtSizes) == 0 {
+		return ""
+	}
 
-	// Sort files by size (descending)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Size > files[j].Size
+	exts := make([]string, 0, len(dir.ExtSizes))
+	for ext := range dir.ExtSizes {
+		exts = append(exts, ext)
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if dir.ExtSizes[exts[i]] != dir.ExtSizes[exts[j]] {
+			return dir.ExtSizes[exts[i]] > dir.ExtSizes[exts[j]]
+		}
+		return exts[i] < exts[j]
 	})
+	if len(exts) > n {
+		exts = exts[:n]
+	}
+
+	parts := make([]string, len(exts))
+	for i, ext := range exts {
+		parts[i] = fmt.Sprintf("%s %.0f%%", ext, float64(dir.ExtSizes[ext])/float64(dir.Size)*100)
+	}
+	return strings.Join(parts, " · ")
+}
 
-	entry.Children = append(entry.Children, directories...)
-	entry.Children = append(entry.Children, files...)
+// SortMode is the order in which the children of a directory are listed
+type SortMode int
 
-	entry.Size = totalSize
+const (
+	SortBySize SortMode = iota
+	SortByName
+	SortByFiles
+	SortByModTime
+)
 
-	// Calculate percentages
-	if totalSize > 0 {
-		for _, child := range entry.Children {
-			child.Percent = float64(child.Size) / float64(totalSize) * 100
+// sortModeNames describe each SortMode, in the order o cycles through them
+var sortModeNames = []string{"size", "name", "file count", "modification time"}
+
+// sortEntries orders a directory's children by mode, directories first.
+// Names sort A to Z ignoring case, counts and times largest and newest first.
+// Ties fall back to size and then name.
+func sortEntries(entries []*scan.DirEntry, mode SortMode) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if a.IsDir != b.IsDir {
+			return a.IsDir
 		}
-	}
+		switch mode {
+		case SortByName:
+			if x, y := strings.ToLower(a.Name), strings.ToLower(b.Name); x != y {
+				return x < y
+			}
+		case SortByFiles:
+			if a.FileCount != b.FileCount {
+				return a.FileCount > b.FileCount
+			}
+			if a.ItemCount != b.ItemCount {
+				return a.ItemCount > b.ItemCount
+			}
+		case SortByModTime:
+			if !a.ModTime.Equal(b.ModTime) {
+				return a.ModTime.After(b.ModTime)
+			}
+		}
+		if a.Size != b.Size {
+			return a.Size > b.Size
+		}
+		return a.Name < b.Name
+	})
+}
 
-	return entry, nil
+// isExecutable reports whether the file has any execute permission bit set
+func isExecutable(filePath string) bool {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return false
+	}
+	return !info.IsDir() && info.Mode()&0111 != 0
 }
 
 func (m Model) executeFile(filePath string) tea.Cmd {
@@ -497,7 +1915,7 @@ func (m Model) executeFile(filePath string) tea.Cmd {
 			// File is executable, run it directly
 			cmd = exec.Command(filePath)
 		} else {
-			cmd = exec.Command("xdg-open", filePath)
+			cmd = exec.Command(openCommand(), filePath)
 		}
 
 		// Set working directory to the file's directory
@@ -513,6 +1931,24 @@ func (m Model) executeFile(filePath string) tea.Cmd {
 	}
 }
 
+// envBool reads a boolean environment variable, falling back to def when unset or invalid
+func envBool(name string, def bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(name))
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// envInt reads a positive integer environment variable, falling back to def when unset or invalid
+func envInt(name string, def int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return def
+	}
+	return value
+}
+
 // printIntegrationCommand prints the export command to add this app to PATH
 func printIntegrationCommand() {
 	execPath, err := os.Executable()
@@ -541,13 +1977,29 @@ func printIntegrationCommand() {
 	fmt.Printf("echo 'export PATH=\"%s:$PATH\"' >> ~/.bashrc\n", binDir)
 }
 
-func main() {
-	currentDir, err := os.Getwd()
+// resolveStartPath turns the path arguments left after the flags into the
+// directory to scan, the working directory if there are none. A file is
+// returned along with its directory.
+func resolveStartPath(args []string) (dir, file string, err error) {
+	arg := "."
+	if len(args) > 0 {
+		arg = args[0]
+	}
+	dir, err = filepath.Abs(arg)
 	if err != nil {
-		fmt.Printf("Error getting current directory: %v\n", err)
-		os.Exit(1)
+		return "", "", fmt.Errorf("%s: %w", arg, err)
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", arg, errors.Unwrap(err))
+	}
+	if !info.IsDir() {
+		return filepath.Dir(dir), dir, nil
 	}
+	return dir, "", nil
+}
 
+func main() {
 	// Check for integration command
 	if len(os.Args) > 1 && os.Args[1] == "/INTEGRATE" {
 		printIntegrationCommand()
@@ -555,29 +2007,297 @@ func main() {
 	}
 
 	// get options
-	showFiles := os.Getenv("USAGE_SHOW_FILES") != "false"
+	defaults := defaultConfig()
+	configPath := flag.String("config", "", "read settings from this JSON file instead of "+defaultConfigPath())
+	noAltScreen := flag.Bool("no-alt-screen", defaults.NoAltScreen,
+		"render inline instead of using the alternate screen (keeps the final view in scrollback)")
+	topDirs := flag.Int("top-dirs", defaults.TopDirs, "number of directories listed by the largest-directories report (T)")
+	label := flag.String("label", defaults.Label, "friendly name shown in the header instead of the start path")
+	colorblind := flag.Bool("colorblind", false, "use the color-blind friendly palette (same as USAGE_PALETTE=colorblind)")
+	oneFS := flag.Bool("one-file-system", defaults.OneFileSystem,
+		"don't descend into other mounted filesystems, except those listed in follow_mounts")
+	csvPath := flag.String("csv", "", "write the start directory's entries to this CSV file (- for stdout) and exit")
+	foldedPath := flag.String("folded", "", "write the tree below the start directory as folded stacks for flame graphs to this file (- for stdout) and exit")
+	monitor := flag.Bool("monitor", false, "instead of the UI, rescan periodically and log the largest and fast-growing files to stdout")
+	monitorInterval := flag.Duration("interval", 5*time.Second, "time between rescans in --monitor and --alert --watch mode")
+	monitorGrowth := flag.String("growth", "100MB", "growth since the last report that --monitor logs for a file")
+	alert := flag.String("alert", "", "instead of the UI, exit with status 1 and a message if the start directory is larger than this size, e.g. 50GB")
+	alertCommand := flag.String("alert-command", "", "shell command run when --alert finds the directory over the limit")
+	sessionName := flag.String("session", "", "reopen the named session where it was left, and save it again on quit")
+	printMode := flag.Bool("print", false, "instead of the UI, print the start directory's entries, largest first, and exit")
+	printDepth := flag.Int("depth", 1, "number of directory levels listed by --print")
+	printTop := flag.Int("top", 0, "with --print, list only the largest N entries of each directory (0 lists all)")
+	watch := flag.Bool("watch", false, "with --alert, keep rescanning every --interval and report each time the limit is crossed")
+	// Paths starting with "-" have to follow "--", which ends the flags
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] [--] [path]\n", filepath.Base(os.Args[0]))
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() > 1 {
+		fmt.Fprintf(os.Stderr, "Expected at most one path, got %d: %s\n", flag.NArg(), strings.Join(flag.Args(), " "))
+		flag.Usage()
+		os.Exit(2)
+	}
 
-	rootDir, err := scanDirectoryWithCache(currentDir, nil, 0, showFiles)
+	// A file given as the start path is shown selected in its directory,
+	// while --print and --csv report it on its own; the other exports cover
+	// its directory
+	startPath, startFile, err := resolveStartPath(flag.Args())
 	if err != nil {
-		fmt.Printf("Error scanning directory: %v\n", err)
+		// Caught here, before any config is read or the UI starts
+		fmt.Fprintf(os.Stderr, "Cannot scan %v\n", err)
 		os.Exit(1)
 	}
 
-	rootDir.Percent = 100.0
+	// A session that wasn't saved yet starts fresh and is saved on quit
+	var session *Session
+	if *sessionName != "" {
+		s, err := loadSession(*sessionName)
+		if err == nil {
+			session = &s
+			if flag.NArg() == 0 && s.StartPath != "" {
+				startPath = s.StartPath
+			}
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(os.Stderr, "Error loading session: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
+	savePath := *configPath
+	if savePath == "" {
+		savePath = defaultConfigPath()
+	}
+	cfg, err := loadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+	if path := findProjectConfig(startPath); path != "" {
+		if err := cfg.applyProjectConfig(path); err != nil && filepath.Dir(path) == startPath {
+			fmt.Fprintf(os.Stderr, "Error loading project config: %v\n", err)
+			os.Exit(1)
+		} else if err != nil {
+			// One further up may belong to an unrelated project, so it
+			// doesn't stop the scan
+			fmt.Fprintf(os.Stderr, "Warning: ignoring project config: %v\n", err)
+		}
+	}
+	cfg.applyEnv()
+
+	// Flags given on the command line win over the config file and environment
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "no-alt-screen":
+			cfg.NoAltScreen = *noAltScreen
+		case "top-dirs":
+			cfg.TopDirs = *topDirs
+		case "label":
+			cfg.Label = *label
+		case "one-file-system":
+			cfg.OneFileSystem = *oneFS
+		case "colorblind":
+			if *colorblind {
+				cfg.Palette = "colorblind"
+			}
+		}
+	})
+	if startFile != "" && !*printMode && *csvPath == "" {
+		// The file has to be listed to be selected
+		cfg.ShowFiles = true
+	}
+	scanner.Exclude = cfg.Exclude
+	for _, path := range cfg.Ignore {
+		scanner.SetIgnored(path, true)
+	}
+	scanner.OneFileSystem = cfg.OneFileSystem
+	scanner.FollowSymlinks = cfg.FollowSymlinks
+	scanner.SetShowHidden(cfg.ShowHidden)
+	// Checked by validate already
+	minSize, _ := parseMinSize(cfg.MinSize)
+	palette, _ := cfg.palette(cfg.Palette)
+	scanner.SetWorkers(cfg.Workers)
+	scanner.SetSizeProvider(scan.SizeProviders[cfg.SizeProvider])
+	scanner.ErrorMode = cfg.ErrorMode
+	scanner.SetFollowMounts(cfg.FollowMounts)
+	if cfg.SkipPseudoFS {
+		scanner.ExcludedPaths = scan.PseudoFSPaths
+	}
+
+	locale := cfg.Locale
+	if locale == "" {
+		locale = systemLocale()
+	}
+
+	// Without a home directory paths are always shown in full
+	home, _ := os.UserHomeDir()
+
+	// LINES/COLUMNS size the first render until a tea.WindowSizeMsg arrives
 	model := Model{
-		RootDir:   rootDir,
-		ShowFiles: showFiles,
-		Error:     nil,
-		CursorPos: 0,
-		ScrollPos: 0,
-		Height:    20,
+		ShowFiles:       cfg.ShowFiles,
+		MaxChildren:     cfg.MaxChildren,
+		ConfigPath:      savePath,
+		Numbers:         numberPrinter(locale),
+		TreeMode:        cfg.EnterAction == "expand",
+		RootRelative:    true,
+		HomeRelative:    cfg.HomeRelative,
+		Home:            home,
+		Error:           nil,
+		CursorPos:       0,
+		ScrollPos:       0,
+		Height:          envInt("LINES", 20),
+		Width:           envInt("COLUMNS", 0),
+		AltScreen:       !cfg.NoAltScreen,
+		TopDirsN:        cfg.TopDirs,
+		StartPath:       startPath,
+		Label:           cfg.Label,
+		WalkScan:        cfg.ScanStrategy == "walk",
+		AdaptiveScan:    cfg.ScanStrategy == "adaptive",
+		MaxDepth:        cfg.MaxDepth,
+		MinSize:         minSize,
+		Quota:           loadQuota(startPath),
+		Disk:            loadDiskSpace(startPath),
+		Palette:         palette,
+		PlainSizes:      cfg.SizeFormat == "plain",
+		HiddenSummary:   cfg.HiddenSummary,
+		PercentDecimals: cfg.PercentDecimals,
+		SessionName:     *sessionName,
+		Trash:           cfg.Trash,
+		frame:           &frameCache{},
+	}
+	if session != nil {
+		session.applySettings(&model)
+	}
+
+	if *monitor {
+		growth, err := humanize.ParseBytes(*monitorGrowth)
+		if err != nil || growth == 0 {
+			fmt.Fprintf(os.Stderr, "Invalid --growth %q: must be a size such as 100MB\n", *monitorGrowth)
+			os.Exit(2)
+		}
+		if *monitorInterval <= 0 {
+			fmt.Fprintf(os.Stderr, "Invalid --interval %v: must be positive\n", *monitorInterval)
+			os.Exit(2)
+		}
+		runMonitor(os.Stdout, startPath, *monitorInterval, int64(growth))
+	}
+
+	if *alert != "" {
+		limit, err := humanize.ParseBytes(*alert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Invalid --alert %q: must be a size such as 50GB\n", *alert)
+			os.Exit(2)
+		}
+		if *watch && *monitorInterval <= 0 {
+			fmt.Fprintf(os.Stderr, "Invalid --interval %v: must be positive\n", *monitorInterval)
+			os.Exit(2)
+		}
+		os.Exit(runAlert(os.Stdout, startPath, int64(limit), *alertCommand, *watch, *monitorInterval))
+	}
+
+	if *foldedPath != "" {
+		if err := exportFolded(model.startScan(), *foldedPath, startPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing folded stacks: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *printMode && *printDepth < 1 {
+		fmt.Fprintf(os.Stderr, "Invalid --depth %d: must be at least 1\n", *printDepth)
+		os.Exit(2)
+	}
+	if *printMode && *printTop < 0 {
+		fmt.Fprintf(os.Stderr, "Invalid --top %d: must not be negative\n", *printTop)
+		os.Exit(2)
+	}
+
+	start := time.Now()
+	if model.WalkScan && model.MaxDepth == 0 && !scanner.FollowSymlinks {
+		scanner.Prime(context.Background(), startPath)
+	}
+	scanOpts := model.scanOptions()
+	if *csvPath != "" || *printMode {
+		// The export is written once, so it needs the final sizes
+		scanOpts.Shallow = false
+	}
+	if startFile != "" && (*printMode || *csvPath != "") {
+		// --print and --csv report the file on its own
+		startPath = startFile
+		model.StartPath = startFile
+	}
+	ctx := model.startScan()
+	rootDir, err := scanner.Scan(ctx, startPath, scanOpts)
+	if err != nil {
+		err = scan.Cause(ctx, err)
+		fmt.Fprintf(os.Stderr, "Error scanning directory: %v\n", err)
+		os.Exit(1)
 	}
+
+	model.RootDir = rootDir
+	model.ScanDuration = time.Since(start)
+	model.ScanFileCount, model.ScanDirCount = int(rootDir.FileCount), int(rootDir.DirCount)
+	model.StartSize = rootDir.Size
 	model.updateVisibleDirs()
+	if startFile != "" && model.selectPath(startFile) {
+		model.ensureCursorVisible()
+	}
+	if session != nil {
+		if flag.NArg() > 0 {
+			// The path given wins over the directory the session was left in
+			session.Path = ""
+		}
+		if err := session.restoreView(ctx, &model); err != nil {
+			fmt.Fprintf(os.Stderr, "Error restoring session: %v\n", err)
+			os.Exit(1)
+		}
+	}
 
-	p := tea.NewProgram(model, tea.WithAltScreen())
-	if _, err := p.Run(); err != nil {
+	if *printMode {
+		if err := model.printTree(ctx, os.Stdout, rootDir, *printDepth, *printTop, scanOpts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error scanning directory: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *csvPath != "" {
+		if err := exportCSV(*csvPath, rootDir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing CSV: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var opts []tea.ProgramOption
+	if model.AltScreen {
+		// Clicks can only be matched to rows when the view starts at the
+		// top of the screen
+		opts = append(opts, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	}
+
+	// Log output would garble the UI, so it only goes to a file when asked for
+	log.SetOutput(io.Discard)
+	if path := os.Getenv("USAGE_DEBUG_LOG"); path != "" {
+		f, err := tea.LogToFile(path, "usage")
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error opening debug log: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+	}
+
+	p := tea.NewProgram(model, opts...)
+	final, err := p.Run()
+	if err != nil {
 		fmt.Printf("Error running program: %v\n", err)
 		os.Exit(1)
 	}
+	if m, ok := final.(Model); ok && m.SessionName != "" {
+		if err := saveSession(m.SessionName, m); err != nil {
+			fmt.Fprintf(os.Stderr, "Error saving session: %v\n", err)
+			os.Exit(1)
+		}
+	}
 }
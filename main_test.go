@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// newTestModel returns a model listing a directory /data/dir with dirs
+// subdirectories and files files of decreasing size, without touching the disk
+func newTestModel(dirs, files int) Model {
+	root := &scan.DirEntry{Name: "dir", Path: "/data/dir", IsDir: true, Percent: 100}
+	add := func(name string, isDir bool, size int64) {
+		child := &scan.DirEntry{
+			Name:      name,
+			Path:      root.Path + "/" + name,
+			IsDir:     isDir,
+			Size:      size,
+			OwnSize:   size / 2,
+			FileCount: 1,
+			Level:     1,
+			ParentDir: root,
+			ModTime:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		}
+		if isDir {
+			child.FileCount, child.DirCount, child.ItemCount = 40, 3, 12
+		}
+		root.Children = append(root.Children, child)
+		root.Size += size
+	}
+	for i := range dirs {
+		add(fmt.Sprintf("dir%03d", i), true, int64(dirs-i)*1_000_000)
+	}
+	for i := range files {
+		add(fmt.Sprintf("file%03d.txt", i), false, int64(files-i)*1_000)
+	}
+	for _, child := range root.Children {
+		child.Percent = float64(child.Size) / float64(root.Size) * 100
+	}
+
+	m := Model{
+		RootDir:         root,
+		StartPath:       root.Path,
+		ShowFiles:       true,
+		Height:          40,
+		Width:           120,
+		Palette:         palettes["default"],
+		PercentDecimals: 1,
+		frame:           &frameCache{},
+	}
+	m.updateVisibleDirs()
+	return m
+}
+
+func TestKeysOnEmptyListing(t *testing.T) {
+	keys := []struct {
+		name string
+		msg  tea.KeyMsg
+	}{
+		{"enter", tea.KeyMsg{Type: tea.KeyEnter}},
+		{"d", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("d")}},
+		{"o", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("o")}},
+		{"y", tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("y")}},
+		{"pgdown", tea.KeyMsg{Type: tea.KeyPgDown}},
+	}
+	for _, key := range keys {
+		t.Run(key.name, func(t *testing.T) {
+			// An empty directory at the root has no .. row either
+			m := newTestModel(0, 0)
+			m.RootDir.Path, m.RootDir.Name = "/", "/"
+			m.updateVisibleDirs()
+			if len(m.VisibleDirs) != 0 {
+				t.Fatalf("listing has %d rows, want none", len(m.VisibleDirs))
+			}
+
+			defer func() {
+				if r := recover(); r != nil {
+					t.Fatalf("%s panicked: %v", key.name, r)
+				}
+			}()
+			tm, _ := m.Update(key.msg)
+			_ = tm.View()
+			if cursor := tm.(Model).CursorPos; cursor != 0 {
+				t.Errorf("cursor at %d after %s, want 0", cursor, key.name)
+			}
+		})
+	}
+}
+
+func TestResolveStartPathAfterDashes(t *testing.T) {
+	root := t.TempDir()
+	dir := filepath.Join(root, "-foo")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "-bar")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	cases := []struct {
+		args              []string
+		wantDir, wantFile string
+	}{
+		{[]string{"--print", "--", "-foo"}, dir, ""},
+		{[]string{"--", "-foo/-bar"}, dir, file},
+		{[]string{"--print"}, root, ""},
+	}
+	for _, c := range cases {
+		flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+		flags.Bool("print", false, "")
+		if err := flags.Parse(c.args); err != nil {
+			t.Fatalf("parsing %q: %v", c.args, err)
+		}
+		gotDir, gotFile, err := resolveStartPath(flags.Args())
+		if err != nil {
+			t.Errorf("%q: %v", c.args, err)
+			continue
+		}
+		if gotDir != c.wantDir || gotFile != c.wantFile {
+			t.Errorf("%q resolved to %q, %q, want %q, %q", c.args, gotDir, gotFile, c.wantDir, c.wantFile)
+		}
+	}
+}
+
+func TestSupersededScanResultsAreDropped(t *testing.T) {
+	m := newTestModel(5, 5)
+	m.startScan()
+	first := m.scanID
+	m.startScan()
+	defer m.cancelScan()
+	m.Loading = true
+
+	// The first scan reports being cancelled by the second one starting
+	for _, msg := range []tea.Msg{
+		LoadingCompleteMsg{Error: context.Canceled, Scan: first},
+		TopDirsMsg{Error: context.Canceled, Scan: first},
+		LoadingCompleteMsg{Dir: &scan.DirEntry{Path: "/elsewhere"}, Scan: first},
+	} {
+		tm, _ := m.Update(msg)
+		got := tm.(Model)
+		if !got.Loading || got.RootDir != m.RootDir {
+			t.Errorf("%T from a superseded scan was applied", msg)
+		}
+	}
+
+	tm, _ := m.Update(LoadingCompleteMsg{Error: context.Canceled, Scan: m.scanID})
+	if tm.(Model).Loading {
+		t.Error("cancelling the current scan left it loading")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/dustin/go-humanize"
+)
+
+// minSizeSteps are the cutoffs m cycles through, smallest first
+var minSizeSteps = []int64{0, 1_000_000, 10_000_000, 100_000_000, 1_000_000_000}
+
+// cycleMinSize raises MinSize to the next step, or back to listing
+// everything after the largest. The cached tree is listed again without
+// scanning, and the selected entry stays selected when it is still listed,
+// or gives way to the closest one above it that is.
+func (m *Model) cycleMinSize() {
+	next := int64(0)
+	for _, step := range minSizeSteps {
+		if step > m.MinSize {
+			next = step
+			break
+		}
+	}
+	m.MinSize = next
+
+	old, cursor, scroll := m.VisibleDirs, m.CursorPos, m.ScrollPos
+	m.updateVisibleDirs()
+	m.ScrollPos = scroll
+	for i := cursor; i >= 0 && i < len(old); i-- {
+		if m.selectPath(old[i].Path) {
+			break
+		}
+	}
+	m.ensureCursorVisible()
+
+	if m.MinSize == 0 {
+		m.Status = "Listing entries of any size"
+	} else {
+		m.Status = fmt.Sprintf("Listing entries of %s or more", humanize.Bytes(uint64(m.MinSize)))
+	}
+}
+
+// parseMinSize reads a cutoff such as "10M" or "1.5GB", where "" means none
+func parseMinSize(value string) (int64, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := humanize.ParseBytes(value)
+	return int64(size), err
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// fileSizes maps every file below the monitored directory to its size
+type fileSizes map[string]int64
+
+// scanFileSizes returns the size of every file below root, walked like the
+// other scans: hidden and excluded entries are skipped, OneFileSystem keeps
+// to root's filesystem and sizes come from the size provider
+func scanFileSizes(root string) fileSizes {
+	sizes := make(fileSizes)
+	scanner.WalkFiles(context.Background(), root, func(path string, size int64) {
+		sizes[path] = size
+	})
+	return sizes
+}
+
+// largest returns the biggest file, preferring the first path alphabetically on ties
+func (s fileSizes) largest() (string, int64) {
+	var path string
+	size := int64(-1)
+	for p, n := range s {
+		if n > size || (n == size && p < path) {
+			path, size = p, n
+		}
+	}
+	return path, size
+}
+
+// runMonitor rescans root every interval and writes a timestamped line to w
+// whenever a different file becomes the largest, or a file has grown by at
+// least growth bytes since it was last reported. It never returns.
+func runMonitor(w io.Writer, root string, interval time.Duration, growth int64) {
+	logf := func(format string, args ...any) {
+		fmt.Fprintf(w, "%s  "+format+"\n", append([]any{time.Now().Format(time.RFC3339)}, args...)...)
+	}
+
+	reported := scanFileSizes(root)
+	largestPath, largestSize := reported.largest()
+	if largestPath != "" {
+		logf("largest  %s  %s", humanize.Bytes(uint64(largestSize)), largestPath)
+	}
+
+	for {
+		time.Sleep(interval)
+		current := scanFileSizes(root)
+
+		for path, size := range current {
+			// New files are measured from zero
+			if size-reported[path] >= growth {
+				logf("grew     %s  +%s  %s", humanize.Bytes(uint64(size)),
+					humanize.Bytes(uint64(size-reported[path])), path)
+				reported[path] = size
+			}
+		}
+		for path := range reported {
+			if _, ok := current[path]; !ok {
+				delete(reported, path)
+			}
+		}
+
+		if path, size := current.largest(); path != "" && path != largestPath {
+			logf("largest  %s  %s", humanize.Bytes(uint64(size)), path)
+			largestPath = path
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// blockSize measures every file as one 4 kB block
+type blockSize struct{}
+
+func (blockSize) FileSize(path string, info fs.FileInfo) int64 {
+	return 4096
+}
+
+func TestMonitorMeasuresFilesWithTheSizeProvider(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.log", ".hidden", "sub/b.log"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	previous := scanner.SizeProvider()
+	scanner.SetSizeProvider(blockSize{})
+	t.Cleanup(func() { scanner.SetSizeProvider(previous) })
+
+	sizes := scanFileSizes(root)
+	want := fileSizes{
+		filepath.Join(root, "a.log"):        4096,
+		filepath.Join(root, "sub", "b.log"): 4096,
+	}
+	if len(sizes) != len(want) {
+		t.Errorf("got %v, want %v", sizes, want)
+	}
+	for path, size := range want {
+		if sizes[path] != size {
+			t.Errorf("size of %s is %d, want %d", path, sizes[path], size)
+		}
+	}
+}
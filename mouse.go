@@ -0,0 +1,85 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// doubleClickTime is the most time between two clicks on a row that still
+// counts as a double click
+const doubleClickTime = 400 * time.Millisecond
+
+// wheelRows is how far one step of the scroll wheel moves the list
+const wheelRows = 3
+
+// updateMouse selects the clicked row, enters a directory that is clicked
+// twice and scrolls the list with the wheel. Clicks outside the rows are
+// ignored, as is the mouse while a pane or prompt covers the list.
+func (m Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.Loading || m.Error != nil || m.ShowErrors || m.ShowIgnored || m.Confirm != nil || m.Input != nil ||
+		m.RootDir == nil || m.singleFile() {
+		return m, nil
+	}
+
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp:
+		m.scrollBy(-wheelRows)
+	case msg.Button == tea.MouseButtonWheelDown:
+		m.scrollBy(wheelRows)
+	case msg.Button == tea.MouseButtonLeft && msg.Action == tea.MouseActionPress:
+		i, ok := m.rowAt(msg.X, msg.Y)
+		if !ok {
+			return m, nil
+		}
+		double := i == m.lastClickRow && time.Since(m.lastClick) < doubleClickTime
+		m.lastClick, m.lastClickRow = time.Now(), i
+		m.CursorPos = i
+		m.ensureCursorVisible()
+
+		dir := m.VisibleDirs[i]
+		if double && dir.IsDir && !dir.Summary {
+			// Same as enter, so the second click doesn't start a third
+			m.lastClick = time.Time{}
+			path := dir.Path
+			return m, func() tea.Msg {
+				return LoadingMsg{Path: path}
+			}
+		}
+	}
+	return m, nil
+}
+
+// rowAt returns the index in VisibleDirs of the row shown at column x and
+// line y, and false when there is no row there
+func (m Model) rowAt(x, y int) (int, bool) {
+	if m.ShowPreview && m.Width > 0 && x >= m.listWidth() {
+		return 0, false
+	}
+	// The header, and the current directory's details when shown, come first
+	top := 1
+	if m.showsRootInfo() {
+		top++
+	}
+	row := y - top
+	if row < 0 || row >= m.listHeight() {
+		return 0, false
+	}
+	i := m.ScrollPos + row
+	if i < 0 || i >= len(m.VisibleDirs) {
+		return 0, false
+	}
+	return i, true
+}
+
+// scrollBy moves the list by rows, keeping the cursor on a visible row
+func (m *Model) scrollBy(rows int) {
+	maxScroll := max(len(m.VisibleDirs)-m.listHeight(), 0)
+	m.ScrollPos = min(max(m.ScrollPos+rows, 0), maxScroll)
+	if m.CursorPos < m.ScrollPos {
+		m.CursorPos = m.ScrollPos
+	} else if last := m.ScrollPos + m.listHeight() - 1; m.CursorPos > last {
+		m.CursorPos = last
+	}
+	m.clampCursor()
+}
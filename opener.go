@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"runtime"
+
+	"github.com/charmbracelet/bubbletea"
+)
+
+// openCommand returns the program that opens files and directories with
+// their default application on this system
+func openCommand() string {
+	switch runtime.GOOS {
+	case "darwin":
+		return "open"
+	case "windows":
+		return "explorer"
+	}
+	return "xdg-open"
+}
+
+// openPath opens path with the system's default application, which for a
+// directory is the file manager. The program is started in its own session
+// so it keeps running after usage quits.
+func openPath(path string) error {
+	bin, err := exec.LookPath(openCommand())
+	if err != nil {
+		return fmt.Errorf("no file manager found: %w", err)
+	}
+	cmd := exec.Command(bin, path)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// Reap it whenever it exits
+	go cmd.Wait()
+	return nil
+}
+
+// openDirCmd opens the selected directory in the file manager
+func (m Model) openDirCmd() tea.Cmd {
+	entry := m.selectedEntry()
+	if entry == nil || !entry.IsDir || entry.Summary {
+		return nil
+	}
+	path := entry.Path
+	return func() tea.Msg {
+		err := openPath(path)
+		return ExecuteFileMsg{path, err == nil, err}
+	}
+}
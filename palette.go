@@ -0,0 +1,88 @@
+package main
+
+import "github.com/charmbracelet/lipgloss"
+
+// Palette holds the colors used by the view. An empty color leaves the
+// terminal's own, see newTheme for how rows stay distinct without them.
+type Palette struct {
+	HeaderFg lipgloss.Color `json:"header_fg"`
+	HeaderBg lipgloss.Color `json:"header_bg"`
+	Selected lipgloss.Color `json:"selected"`
+	Dir      lipgloss.Color `json:"dir"`
+	File     lipgloss.Color `json:"file"`
+	Size     lipgloss.Color `json:"size"`
+	Percent  lipgloss.Color `json:"percent"`
+	Muted    lipgloss.Color `json:"muted"`
+	Error    lipgloss.Color `json:"error"`
+	// BarOwn and BarNested color the usage bar segments for an entry's own
+	// files and for its subdirectories
+	BarOwn    lipgloss.Color `json:"bar_own"`
+	BarNested lipgloss.Color `json:"bar_nested"`
+	// Changed is the background of rows that changed in the last re-scan
+	Changed lipgloss.Color `json:"changed"`
+	// Hot and Warm color the names and sizes of entries taking more than
+	// hotPercent and warmPercent of their directory
+	Hot  lipgloss.Color `json:"hot"`
+	Warm lipgloss.Color `json:"warm"`
+}
+
+// darkPalette is the default, made for dark terminal backgrounds
+var darkPalette = Palette{
+	HeaderFg:  "226", // Bright yellow text
+	HeaderBg:  "235", // Dark gray background
+	Selected:  "240",
+	Dir:       "39",
+	File:      "252",
+	Size:      "248",
+	Percent:   "214",
+	Muted:     "245",
+	Error:     "203",
+	BarOwn:    "114",
+	BarNested: "39",
+	Changed:   "22",
+	Hot:       "196",
+	Warm:      "220",
+}
+
+// palettes are selectable via USAGE_THEME or USAGE_PALETTE, and the config
+// file may add more under "themes". The colorblind palette avoids red/green
+// contrasts and relies on blue and orange, which stay distinct for the
+// common forms of color vision deficiency. mono leaves every color to the
+// terminal.
+var palettes = map[string]Palette{
+	"default": darkPalette,
+	"dark":    darkPalette,
+	"light": {
+		HeaderFg:  "231", // White text
+		HeaderBg:  "25",  // Blue background
+		Selected:  "252",
+		Dir:       "25",
+		File:      "235",
+		Size:      "240",
+		Percent:   "130",
+		Muted:     "243",
+		Error:     "160",
+		BarOwn:    "28",
+		BarNested: "25",
+		Changed:   "194",
+		Hot:       "160",
+		Warm:      "136",
+	},
+	"mono": {},
+	"colorblind": {
+		HeaderFg:  "231",
+		HeaderBg:  "24",
+		Selected:  "240",
+		Dir:       "33",
+		File:      "252",
+		Size:      "248",
+		Percent:   "208",
+		Muted:     "245",
+		Error:     "202",
+		BarOwn:    "208",
+		BarNested: "33",
+		Changed:   "17",
+		Hot:       "202",
+		Warm:      "228",
+	},
+}
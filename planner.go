@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/dustin/go-humanize"
+
+	"usage/scan"
+)
+
+// promptFreeSpace asks how much space to free and plans which entries to delete
+func (m *Model) promptFreeSpace() {
+	m.Input = &InputPrompt{
+		Prompt: "Free how much (e.g. 5 GB): ",
+		OnSubmit: func(m *Model, value string) tea.Cmd {
+			target, err := humanize.ParseBytes(value)
+			if err != nil || target == 0 {
+				m.Status = fmt.Sprintf("Not a size: %q", value)
+				return nil
+			}
+			m.planFreeSpace(int64(target))
+			m.ensureCursorVisible()
+			return nil
+		},
+	}
+}
+
+// planCandidates returns the entries listed directly in the current directory,
+// largest first
+func (m Model) planCandidates() []*scan.DirEntry {
+	var candidates []*scan.DirEntry
+	for _, entry := range m.VisibleDirs {
+		if entry.ParentDir == m.RootDir && !entry.Summary {
+			candidates = append(candidates, entry)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Size > candidates[j].Size
+	})
+	return candidates
+}
+
+// planFreeSpace selects the fewest entries of the current directory that
+// together free at least target bytes. Taking the largest ones first needs
+// the fewest; the last one taken is then swapped for the smallest entry that
+// still reaches the target, so no more is deleted than necessary.
+func (m *Model) planFreeSpace(target int64) {
+	candidates := m.planCandidates()
+	m.Plan = make(map[string]bool)
+	m.PlanTarget = target
+
+	var total int64
+	n := 0
+	for n < len(candidates) && total < target {
+		total += candidates[n].Size
+		n++
+	}
+	if total < target {
+		for _, entry := range candidates {
+			m.Plan[entry.Path] = true
+		}
+		m.Status = fmt.Sprintf("Only %s can be freed here", humanize.Bytes(uint64(total)))
+		return
+	}
+
+	for _, entry := range candidates[:n] {
+		m.Plan[entry.Path] = true
+	}
+	if n == 0 {
+		return
+	}
+	rest := total - candidates[n-1].Size
+	for i := len(candidates) - 1; i >= n; i-- {
+		if rest+candidates[i].Size >= target {
+			delete(m.Plan, candidates[n-1].Path)
+			m.Plan[candidates[i].Path] = true
+			break
+		}
+	}
+}
+
+// togglePlanned adds the selected entry to the plan or takes it out
+func (m *Model) togglePlanned() {
+	entry := m.selectedEntry()
+	if entry == nil || entry.ParentDir != m.RootDir || entry.Summary {
+		return
+	}
+	if m.Plan[entry.Path] {
+		delete(m.Plan, entry.Path)
+	} else {
+		m.Plan[entry.Path] = true
+	}
+}
+
+// planned returns the paths in the plan, in list order, and their total size
+func (m Model) planned() ([]string, int64) {
+	var paths []string
+	var total int64
+	for _, entry := range m.VisibleDirs {
+		if m.Plan[entry.Path] {
+			paths = append(paths, entry.Path)
+			total += entry.Size
+		}
+	}
+	return paths, total
+}
+
+// confirmPlan asks before deleting everything in the plan
+func (m *Model) confirmPlan() {
+	paths, total := m.planned()
+	if len(paths) == 0 {
+		m.Status = "Nothing planned"
+		return
+	}
+	prompt := fmt.Sprintf("Delete %d entries (%s) for good? [y/N]", len(paths), humanize.Bytes(uint64(total)))
+	if m.Trash {
+		prompt = fmt.Sprintf("Move %d entries (%s) to the trash? [y/N]", len(paths), humanize.Bytes(uint64(total)))
+	}
+	m.Confirm = &Confirmation{
+		Prompt: prompt,
+		OnYes:  m.removeCmd(paths),
+	}
+}
+
+// planSummary is the footer line with the plan's running total
+func (m Model) planSummary() string {
+	paths, total := m.planned()
+	line := fmt.Sprintf("Plan: %d entries, %s of %s", len(paths),
+		humanize.Bytes(uint64(total)), humanize.Bytes(uint64(m.PlanTarget)))
+	if total < m.PlanTarget {
+		line += fmt.Sprintf(" (%s short)", humanize.Bytes(uint64(m.PlanTarget-total)))
+	}
+	return line + "  |  x adds/removes, D deletes, F clears"
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// previewBytes is how much of a file is read for the preview pane
+const previewBytes = 8 * 1024
+
+// previewLines is how many lines of a file are kept, more than any pane is tall
+const previewLines = 1000
+
+// previewCacheSize is how many files' previews are kept before starting over
+const previewCacheSize = 256
+
+// previewText is the head of a file loaded for the preview pane
+type previewText struct {
+	// size and modTime are the entry's when it was loaded, so a file that
+	// changed since is loaded again
+	size    int64
+	modTime time.Time
+	lines   []string
+	// note is shown instead of the lines while loading, for binary files,
+	// for files that aren't regular and for errors
+	note string
+}
+
+// PreviewMsg is sent when the head of a file has been read for the preview pane
+type PreviewMsg struct {
+	Path    string
+	Preview previewText
+}
+
+// readPreview returns up to maxLines lines from the head of a text file.
+// Files containing NUL bytes or invalid UTF-8 are reported as binary.
+func readPreview(path string, maxLines int) (lines []string, binary bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	buf := make([]byte, previewBytes)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return nil, false, err
+	}
+	buf = buf[:n]
+
+	// A multi-byte rune may have been cut off at the end of the buffer
+	check := buf
+	if n == previewBytes {
+		for i := 0; i < utf8.UTFMax && len(check) > 0 && !utf8.Valid(check); i++ {
+			check = check[:len(check)-1]
+		}
+	}
+	if bytes.IndexByte(buf, 0) >= 0 || !utf8.Valid(check) {
+		return nil, true, nil
+	}
+
+	for _, line := range strings.Split(string(check), "\n") {
+		if len(lines) == maxLines {
+			break
+		}
+		lines = append(lines, strings.ReplaceAll(strings.TrimRight(line, "\r"), "\t", "    "))
+	}
+	return lines, false, nil
+}
+
+// loadPreview starts reading the selected file for the preview pane unless it
+// is cached already. View only renders cached previews, so a slow file can't
+// hold up the UI. Only regular files are opened: opening a FIFO blocks until
+// something writes to it, and reading a device may never end.
+func (m *Model) loadPreview() tea.Cmd {
+	if !m.ShowPreview || m.CursorPos >= len(m.VisibleDirs) {
+		return nil
+	}
+	entry := m.VisibleDirs[m.CursorPos]
+	if entry.IsDir || entry.Summary {
+		return nil
+	}
+	if cached, ok := m.previews[entry.Path]; ok && cached.size == entry.Size && cached.modTime.Equal(entry.ModTime) {
+		return nil
+	}
+	if m.previews == nil || len(m.previews) >= previewCacheSize {
+		m.previews = make(map[string]previewText)
+	}
+	preview := previewText{size: entry.Size, modTime: entry.ModTime, note: "loading..."}
+	m.previews[entry.Path] = preview
+
+	path := entry.Path
+	return func() tea.Msg {
+		preview.note = ""
+		info, err := os.Lstat(path)
+		switch {
+		case err != nil:
+			preview.note = err.Error()
+		case !info.Mode().IsRegular():
+			preview.note = "not a regular file"
+		default:
+			lines, binary, err := readPreview(path, previewLines)
+			switch {
+			case err != nil:
+				preview.note = err.Error()
+			case binary:
+				preview.note = "binary file"
+			default:
+				preview.lines = lines
+			}
+		}
+		return PreviewMsg{path, preview}
+	}
+}
+
+// previewView renders the cached head of the selected file into a pane of the given size
+func (m Model) previewView(width, height int) string {
+	borderStyle := m.theme().Preview
+	noteStyle := m.theme().Note
+
+	// Border and padding take two columns
+	innerWidth := width - 2
+	if innerWidth < 1 || height < 1 {
+		return ""
+	}
+	// Copied, as setting rules on a style changes the one it was taken from
+	pane := borderStyle.Copy().Width(innerWidth).MaxWidth(width).Height(height).MaxHeight(height)
+
+	if m.CursorPos >= len(m.VisibleDirs) {
+		return pane.Render("")
+	}
+	entry := m.VisibleDirs[m.CursorPos]
+	if entry.Summary {
+		return pane.Render(noteStyle.Render("(press enter to list them)"))
+	}
+	if entry.IsDir {
+		return pane.Render(noteStyle.Render("(directory)"))
+	}
+
+	preview, ok := m.previews[entry.Path]
+	if !ok {
+		preview.note = "loading..."
+	}
+	if preview.note != "" {
+		return pane.Render(noteStyle.Render("(" + preview.note + ")"))
+	}
+
+	// Cut long lines instead of letting the pane wrap them, leaving the cached ones alone
+	lines := make([]string, 0, min(len(preview.lines), height))
+	for _, line := range preview.lines[:min(len(preview.lines), height)] {
+		if runes := []rune(line); len(runes) > innerWidth {
+			line = string(runes[:innerWidth])
+		}
+		lines = append(lines, line)
+	}
+	return pane.Render(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// previewMsg runs cmd, and the commands of a batch, for the PreviewMsg among their messages
+func previewMsg(t *testing.T, cmd tea.Cmd) PreviewMsg {
+	t.Helper()
+	if cmd == nil {
+		t.Fatal("no command to read the preview")
+	}
+	switch msg := cmd().(type) {
+	case PreviewMsg:
+		return msg
+	case tea.BatchMsg:
+		for _, cmd := range msg {
+			if cmd == nil {
+				continue
+			}
+			if msg, ok := cmd().(PreviewMsg); ok {
+				return msg
+			}
+		}
+	}
+	t.Fatal("the preview wasn't read")
+	return PreviewMsg{}
+}
+
+// selectPreviewFile points the last listed entry, a file, at path and selects it
+func (m *Model) selectPreviewFile(path string) {
+	m.CursorPos = len(m.VisibleDirs) - 1
+	m.VisibleDirs[m.CursorPos].Path = path
+}
+
+func TestPreviewIsReadInTheBackground(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	if err := os.WriteFile(path, []byte("first line\nsecond line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	m := newTestModel(0, 1)
+	m.selectPreviewFile(path)
+
+	tm, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("p")})
+	m = tm.(Model)
+	if view := m.View(); !strings.Contains(view, "(loading...)") {
+		t.Errorf("preview pane before the file is read:\n%s", view)
+	}
+
+	tm, _ = m.Update(previewMsg(t, cmd))
+	m = tm.(Model)
+	if view := m.View(); !strings.Contains(view, "second line") {
+		t.Errorf("preview pane after the file is read:\n%s", view)
+	}
+	if _, cmd := m.Update(tea.KeyMsg{Type: tea.KeyDown}); cmd != nil {
+		t.Error("the cached preview was read again")
+	}
+}
+
+func TestPreviewSkipsFilesThatAreNotRegular(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	if err := os.WriteFile(target, []byte("text\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip(err)
+	}
+	m := newTestModel(0, 1)
+	m.ShowPreview = true
+	m.selectPreviewFile(link)
+
+	if msg := previewMsg(t, m.loadPreview()); msg.Preview.note != "not a regular file" || msg.Preview.lines != nil {
+		t.Errorf("preview of a symbolic link: %+v", msg.Preview)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build unix
+
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestPreviewOfAFIFODoesNotBlock(t *testing.T) {
+	fifo := filepath.Join(t.TempDir(), "fifo")
+	if err := syscall.Mkfifo(fifo, 0o600); err != nil {
+		t.Skip(err)
+	}
+	m := newTestModel(0, 1)
+	m.ShowPreview = true
+	m.selectPreviewFile(fifo)
+
+	// Opening the FIFO would wait for a writer that never comes
+	done := make(chan string, 1)
+	go func() {
+		cmd := m.loadPreview()
+		view := m.View()
+		if msg, ok := cmd().(PreviewMsg); ok {
+			view += msg.Preview.note
+		}
+		done <- view
+	}()
+	select {
+	case view := <-done:
+		if !strings.Contains(view, "(loading...)") || !strings.HasSuffix(view, "not a regular file") {
+			t.Errorf("preview of a FIFO:\n%s", view)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("previewing a FIFO blocked")
+	}
+}
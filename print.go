@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"usage/scan"
+)
+
+// printTree writes the entries of dir to w, largest first, one per line with
+// its size and its share of the directory it is in. depth is the number of
+// levels listed, with directories below the first level scanned as they are
+// reached, and top limits each directory to its largest entries (0 lists all).
+// A file given as dir is printed as one line with its size and path.
+func (m Model) printTree(ctx context.Context, w io.Writer, dir *scan.DirEntry, depth, top int, opts scan.Options) error {
+	if !dir.IsDir {
+		_, err := fmt.Fprintf(w, "%s  %s\n", formatSize(dir.Size, m.PlainSizes), dir.Path)
+		return err
+	}
+
+	children := append([]*scan.DirEntry(nil), dir.Children...)
+	scan.SortBySize(children)
+	if top > 0 && len(children) > top {
+		children = children[:top]
+	}
+
+	indent := strings.Repeat("  ", dir.Level)
+	for _, child := range children {
+		name := child.Name
+		if child.IsDir && !child.Summary {
+			name += "/"
+		}
+		if _, err := fmt.Fprintf(w, "%s  %s  %s%s\n", formatSize(child.Size, m.PlainSizes),
+			m.formatPercent(child.Percent), indent, name); err != nil {
+			return err
+		}
+
+		if depth <= 1 || !child.IsDir || child.Summary || child.MountPoint {
+			continue
+		}
+		sub, err := scanner.ScanDir(ctx, child.Path, dir, dir.Level+1, opts)
+		if err != nil {
+			return scan.Cause(ctx, err)
+		}
+		if err := m.printTree(ctx, w, sub, depth-1, top, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"usage/scan"
+)
+
+func TestFileStartPathIsOneLine(t *testing.T) {
+	file := &scan.DirEntry{Name: "big.iso", Path: "/data/big.iso", Size: 4096, FileCount: 1, Percent: 100}
+	m := newTestModel(0, 0)
+
+	var out bytes.Buffer
+	if err := m.printTree(context.Background(), &out, file, 2, 0, m.scanOptions()); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n"); len(lines) != 1 || !strings.HasSuffix(lines[0], "  /data/big.iso") {
+		t.Errorf("--print wrote %q, want one line ending in the file's path", out.String())
+	}
+
+	out.Reset()
+	if err := writeCSV(&out, file); err != nil {
+		t.Fatal(err)
+	}
+	want := "name,path,size,percent,is_dir,file_count\nbig.iso,/data/big.iso,4096,100.00,false,1\n"
+	if out.String() != want {
+		t.Errorf("--csv wrote %q, want %q", out.String(), want)
+	}
+}
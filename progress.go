@@ -0,0 +1,29 @@
+package main
+
+import (
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// progressInterval is how often the loading view picks up the scan's progress,
+// so large scans don't flood the program with messages
+const progressInterval = 100 * time.Millisecond
+
+// ScanProgressMsg reports how many entries the running scan has visited and
+// the directory it is in
+type ScanProgressMsg struct {
+	Count   int64
+	Current string
+	// progress identifies the scan, so reports of an older one are dropped
+	progress *scan.Progress
+}
+
+// progressCmd reports the progress of the scan after progressInterval
+func progressCmd(progress *scan.Progress) tea.Cmd {
+	return tea.Tick(progressInterval, func(time.Time) tea.Msg {
+		return ScanProgressMsg{Count: progress.Count(), Current: progress.Current(), progress: progress}
+	})
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// projectConfigName is the per-project settings file looked up from the start
+// directory upwards
+const projectConfigName = ".usage.toml"
+
+// findProjectConfig returns the path of the .usage.toml in dir or its nearest
+// ancestor that has one, or "" when there is none
+func findProjectConfig(dir string) string {
+	for {
+		path := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// applyProjectConfig reads the project file at path over c. Keys are the same
+// as in the JSON config; exclude patterns and ignored paths are added to the
+// configured ones. c is left as it was when the file has an error.
+func (c *Config) applyProjectConfig(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	values, err := parseTOML(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Decode through JSON so the keys and types are checked like the config file
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	// Decoded into a copy, as decoding reuses the maps and slices it fills
+	updated := *c
+	updated.Themes = maps.Clone(c.Themes)
+	updated.FollowMounts = slices.Clone(c.FollowMounts)
+	updated.Exclude, updated.Ignore = nil, nil
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&updated); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	updated.Exclude = append(slices.Clip(c.Exclude), updated.Exclude...)
+	updated.Ignore = append(slices.Clip(c.Ignore), updated.Ignore...)
+	if err := updated.validate(); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	*c = updated
+	return nil
+}
+
+// parseTOML parses the flat subset of TOML used by .usage.toml: key = value
+// lines with strings, integers, booleans and single-line arrays of those.
+// Tables are not supported.
+func parseTOML(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported", i+1)
+		}
+
+		key, rest, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key = strings.Trim(strings.TrimSpace(key), `"`)
+		if _, dup := values[key]; dup {
+			return nil, fmt.Errorf("line %d: %s is set twice", i+1, key)
+		}
+
+		value, rest, err := parseTOMLValue(strings.TrimSpace(rest))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", i+1, err)
+		}
+		if rest = strings.TrimSpace(rest); rest != "" && !strings.HasPrefix(rest, "#") {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", i+1, rest)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+// parseTOMLValue parses the value at the start of s and returns the unparsed rest
+func parseTOMLValue(s string) (any, string, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return parseTOMLBasicString(s[1:])
+	case strings.HasPrefix(s, "'"):
+		// Literal strings have no escapes
+		value, rest, ok := strings.Cut(s[1:], "'")
+		if !ok {
+			return nil, "", errors.New("unterminated string")
+		}
+		return value, rest, nil
+	case strings.HasPrefix(s, "["):
+		var values []any
+		s = strings.TrimSpace(s[1:])
+		for !strings.HasPrefix(s, "]") {
+			value, rest, err := parseTOMLValue(s)
+			if err != nil {
+				return nil, "", err
+			}
+			values = append(values, value)
+			s = strings.TrimSpace(rest)
+			if strings.HasPrefix(s, ",") {
+				s = strings.TrimSpace(s[1:])
+			} else if !strings.HasPrefix(s, "]") {
+				return nil, "", errors.New("expected , or ] in array")
+			}
+		}
+		return values, s[1:], nil
+	}
+
+	// Bare values end at whitespace, a comma, a closing bracket or a comment
+	end := strings.IndexAny(s, " \t,]#")
+	if end < 0 {
+		end = len(s)
+	}
+	word, rest := s[:end], s[end:]
+	switch word {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+	n, err := strconv.ParseInt(strings.ReplaceAll(word, "_", ""), 10, 64)
+	if err != nil {
+		return nil, "", fmt.Errorf("unsupported value %q", word)
+	}
+	return n, rest, nil
+}
+
+// tomlEscapes are the single-character escapes of TOML basic strings
+var tomlEscapes = map[byte]byte{
+	'b': '\b', 't': '\t', 'n': '\n', 'f': '\f', 'r': '\r', '"': '"', '\\': '\\',
+}
+
+// parseTOMLBasicString parses the rest of a basic string whose opening quote
+// has been taken off s, and returns the unparsed rest after the closing quote.
+// Escapes are TOML's, which Go's differ from, e.g. \a and \x41 are errors.
+func parseTOMLBasicString(s string) (string, string, error) {
+	var value strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			return value.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", errors.New("unterminated string")
+			}
+			if c, ok := tomlEscapes[s[i]]; ok {
+				value.WriteByte(c)
+				continue
+			}
+			// \uXXXX and \UXXXXXXXX name a code point in hex
+			var digits int
+			switch s[i] {
+			case 'u':
+				digits = 4
+			case 'U':
+				digits = 8
+			}
+			if digits == 0 || i+digits >= len(s) {
+				return "", "", fmt.Errorf("invalid escape \\%c in string", s[i])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+digits], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", "", fmt.Errorf("invalid escape \\%s in string", s[i:i+1+digits])
+			}
+			value.WriteRune(rune(code))
+			i += digits
+		default:
+			value.WriteByte(s[i])
+		}
+	}
+	return "", "", errors.New("unterminated string")
+}
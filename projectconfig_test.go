@@ -0,0 +1,57 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseTOMLStrings(t *testing.T) {
+	cases := []struct {
+		line string
+		want string
+	}{
+		{`label = "plain"`, "plain"},
+		{`label = "tab\there \"quoted\" back\\slash"`, "tab\there \"quoted\" back\\slash"},
+		{`label = "caf\u00e9 \U0001F600"`, "café 😀"},
+		{`label = 'C:\Users\me'`, `C:\Users\me`},
+		{`label = 'no "escapes" \n here' # comment`, `no "escapes" \n here`},
+		{`label = "a # not a comment" # but this is`, "a # not a comment"},
+	}
+	for _, c := range cases {
+		values, err := parseTOML([]byte(c.line))
+		if err != nil {
+			t.Errorf("%s: %v", c.line, err)
+			continue
+		}
+		if got := values["label"]; got != c.want {
+			t.Errorf("%s: got %q, want %q", c.line, got, c.want)
+		}
+	}
+
+	// Go escapes that TOML doesn't have
+	for _, line := range []string{`label = "\a"`, `label = "\x41"`, `label = "\101"`, `label = "\'"`, `label = "\u12"`, `label = "open`} {
+		if values, err := parseTOML([]byte(line)); err == nil {
+			t.Errorf("%s: got %q, want an error", line, values["label"])
+		}
+	}
+}
+
+func TestProjectConfigErrorLeavesConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), projectConfigName)
+	// The error comes after settings that would be applied
+	if err := os.WriteFile(path, []byte("label = \"other\"\nexclude = [\"dist\"]\nmax_children = \"many\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := defaultConfig()
+	cfg.Exclude = []string{"node_modules"}
+	want := cfg
+
+	if err := cfg.applyProjectConfig(path); err == nil {
+		t.Fatal("no error for a string max_children")
+	}
+	if cfg.Label != want.Label || cfg.MaxChildren != want.MaxChildren || !slices.Equal(cfg.Exclude, want.Exclude) {
+		t.Errorf("config changed by a project file with an error: %+v", cfg)
+	}
+}
@@ -0,0 +1,8 @@
+package main
+
+// QuotaInfo is the usage and limit of the disk quota that applies to a path
+type QuotaInfo struct {
+	Kind  string // "user" or "group"
+	Used  int64
+	Limit int64
+}
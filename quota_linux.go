@@ -0,0 +1,81 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+
+	"usage/scan"
+)
+
+// Constants from <linux/quota.h>
+const (
+	qGetQuota   = 0x800007
+	usrQuota    = 0
+	grpQuota    = 1
+	qifBlockLen = 1024
+)
+
+// ifDqblk mirrors struct if_dqblk
+type ifDqblk struct {
+	BHardLimit uint64
+	BSoftLimit uint64
+	CurSpace   uint64
+	IHardLimit uint64
+	ISoftLimit uint64
+	CurInodes  uint64
+	BTime      uint64
+	ITime      uint64
+	Valid      uint32
+}
+
+// getQuota returns the user (or failing that, group) block quota of the
+// filesystem containing path. ok is false when no quota is enforced.
+func getQuota(path string) (QuotaInfo, bool) {
+	device := mountDevice(path)
+	if device == "" {
+		return QuotaInfo{}, false
+	}
+
+	if info, ok := queryQuota(device, usrQuota, os.Getuid()); ok {
+		info.Kind = "user"
+		return info, true
+	}
+	if info, ok := queryQuota(device, grpQuota, os.Getgid()); ok {
+		info.Kind = "group"
+		return info, true
+	}
+	return QuotaInfo{}, false
+}
+
+func queryQuota(device string, quotaType, id int) (QuotaInfo, bool) {
+	devicePtr, err := syscall.BytePtrFromString(device)
+	if err != nil {
+		return QuotaInfo{}, false
+	}
+
+	var dq ifDqblk
+	cmd := qGetQuota<<8 | quotaType
+	_, _, errno := syscall.Syscall6(syscall.SYS_QUOTACTL, uintptr(cmd), uintptr(unsafe.Pointer(devicePtr)),
+		uintptr(id), uintptr(unsafe.Pointer(&dq)), 0, 0)
+	if errno != 0 {
+		return QuotaInfo{}, false
+	}
+
+	limit := dq.BHardLimit
+	if limit == 0 {
+		limit = dq.BSoftLimit
+	}
+	if limit == 0 {
+		return QuotaInfo{}, false
+	}
+	return QuotaInfo{Used: int64(dq.CurSpace), Limit: int64(limit * qifBlockLen)}, true
+}
+
+// mountDevice returns the device of the longest mount point containing path
+func mountDevice(path string) string {
+	device, _ := scan.FindMount(path)
+	return device
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package main
+
+// getQuota is only implemented on Linux
+func getQuota(path string) (QuotaInfo, bool) {
+	return QuotaInfo{}, false
+}
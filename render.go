@@ -0,0 +1,68 @@
+package main
+
+import tea "github.com/charmbracelet/bubbletea"
+
+// frameCache keeps the last rendered frame. bubbletea calls View after every
+// message, and most key presses only move the cursor or do nothing at all, so
+// the frame is reused as long as frameKey is unchanged.
+type frameCache struct {
+	// generation is bumped by every message that may change what is shown
+	// beyond the positions in frameKey, including entries changed in place
+	generation int
+	key        frameKey
+	view       string
+	valid      bool
+}
+
+// frameKey is what a frame depends on besides the generation
+type frameKey struct {
+	generation   int
+	cursor       int
+	scroll       int
+	errorScroll  int
+	ignoreCursor int
+	status       string
+	pendingKey   string
+}
+
+// navigationKeys only move the cursor or the scroll position
+var navigationKeys = map[string]bool{
+	"up": true, "k": true, "down": true, "j": true,
+	"pgup": true, "pgdown": true, "home": true, "g": true, "end": true, "G": true,
+}
+
+// changesFrame reports whether msg may change the frame in ways frameKey
+// doesn't capture. Keys typed into a prompt edit it in place.
+func (m Model) changesFrame(msg tea.Msg) bool {
+	if _, ok := msg.(tea.MouseMsg); ok {
+		// The mouse only moves the cursor or the scroll position
+		return false
+	}
+	key, ok := msg.(tea.KeyMsg)
+	if !ok || m.Input != nil || m.Confirm != nil || m.Loading || m.Filtering {
+		return true
+	}
+	return !navigationKeys[key.String()]
+}
+
+// View renders the model, reusing the previous frame when nothing changed
+func (m Model) View() string {
+	if m.frame == nil {
+		return m.render()
+	}
+	key := frameKey{
+		generation:   m.frame.generation,
+		cursor:       m.CursorPos,
+		scroll:       m.ScrollPos,
+		errorScroll:  m.ErrorScroll,
+		ignoreCursor: m.IgnoreCursor,
+		status:       m.Status,
+		pendingKey:   m.PendingKey,
+	}
+	if m.frame.valid && m.frame.key == key {
+		return m.frame.view
+	}
+	view := m.render()
+	m.frame.key, m.frame.view, m.frame.valid = key, view, true
+	return view
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// BenchmarkViewFrame measures the frame reuse on a listing of a few hundred
+// entries: "repeated" is a frame asked for again with nothing changed, as
+// after a no-op key, "cursor move" moves the cursor down and back up through
+// Update, which renders again, and "uncached" renders every call.
+func BenchmarkViewFrame(b *testing.B) {
+	b.Run("repeated", func(b *testing.B) {
+		m := newTestModel(200, 200)
+		_ = m.View()
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = m.View()
+		}
+	})
+	b.Run("cursor move", func(b *testing.B) {
+		var tm tea.Model = newTestModel(200, 200)
+		down := tea.KeyMsg{Type: tea.KeyDown}
+		up := tea.KeyMsg{Type: tea.KeyUp}
+		b.ReportAllocs()
+		for i := 0; b.Loop(); i++ {
+			key := down
+			if i%2 == 1 {
+				key = up
+			}
+			tm, _ = tm.Update(key)
+			_ = tm.View()
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		m := newTestModel(200, 200)
+		m.frame = nil
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = m.View()
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package scan
+
+import "context"
+
+// shallowSize returns the cached totals of path, or else the size of the
+// files directly inside it. refining is set when that leaves out subdirectories.
+func (s *Scanner) shallowSize(ctx context.Context, path string, maxDepth int) (totals Totals, refining bool) {
+	if totals, ok := s.CachedTotals(path, maxDepth); ok {
+		return totals, false
+	}
+
+	totals, final := s.SizeLevels(ctx, path, 0, maxDepth)
+	return totals, !final
+}
+
+// SizeLevels sums up path to depth levels below it, but no further than
+// maxDepth allows. final is set when there is nothing left to sum, because the
+// totals are complete or reached maxDepth; final totals are cached. Calling
+// it with growing depths refines the sizes of a Shallow scan.
+func (s *Scanner) SizeLevels(ctx context.Context, path string, depth, maxDepth int) (totals Totals, final bool) {
+	if maxDepth > 0 && depth > maxDepth-1 {
+		depth = maxDepth - 1
+	}
+	totals, complete := s.sizeDir(ctx, path, depth, nil)
+	if ctx.Err() != nil {
+		return totals, false
+	}
+	final = complete || (maxDepth > 0 && depth == maxDepth-1)
+	if !complete && final {
+		totals.Truncated = true
+		totals.Depth = maxDepth
+	}
+	if final {
+		s.Store(path, totals)
+	}
+	return totals, final
+}
@@ -0,0 +1,16 @@
+//go:build darwin || windows
+
+package scan
+
+import "strings"
+
+// cacheKey makes path absolute and folds case so /Foo and /foo share one
+// size cache entry, as they name the same directory on the default
+// (case-insensitive) filesystems of macOS and Windows. On a case-sensitive
+// volume there they are two directories sharing the entry, so one can be
+// listed with the other's size until it is re-scanned, and Invalidate drops
+// both. The ignore list and the mount points to follow hold paths the user
+// gave, and are compared exactly instead.
+func cacheKey(path string) string {
+	return strings.ToLower(AbsPath(path))
+}
@@ -0,0 +1,8 @@
+//go:build !darwin && !windows
+
+package scan
+
+// cacheKey returns the absolute form of path; filesystems here are case-sensitive
+func cacheKey(path string) string {
+	return AbsPath(path)
+}
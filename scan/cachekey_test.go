@@ -0,0 +1,49 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestPathsDifferingInCaseStayApart(t *testing.T) {
+	root := t.TempDir()
+	upper, lower := filepath.Join(root, "Foo"), filepath.Join(root, "foo")
+	writeTree(t, root, map[string]int{"Foo/a.txt": 100})
+	if err := os.Mkdir(lower, 0o755); errors.Is(err, fs.ErrExist) {
+		t.Skip("the temporary directory is on a case-insensitive filesystem")
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, root, map[string]int{"foo/b.txt": 300})
+
+	s := New()
+	s.SetIgnored(upper, true)
+	if !s.IsIgnored(upper) || s.IsIgnored(lower) {
+		t.Errorf("after ignoring %s: ignored %v, %s ignored %v", upper, s.IsIgnored(upper), lower, s.IsIgnored(lower))
+	}
+	s.SetFollowMounts([]string{upper})
+	if !s.followMounts[AbsPath(upper)] || s.followMounts[AbsPath(lower)] {
+		t.Errorf("following %s follows %v", upper, s.followMounts)
+	}
+
+	if cacheKey(upper) == cacheKey(lower) {
+		// Sizes are shared where cache keys fold case, see cacheKey
+		return
+	}
+	s = New()
+	ctx := context.Background()
+	if size := s.Size(ctx, upper, 0).Size; size != 100 {
+		t.Errorf("size of %s is %d, want 100", upper, size)
+	}
+	if size := s.Size(ctx, lower, 0).Size; size != 300 {
+		t.Errorf("size of %s is %d, want 300", lower, size)
+	}
+	s.Invalidate(upper)
+	if _, ok := s.CachedTotals(lower, 0); !ok {
+		t.Errorf("invalidating %s dropped %s", upper, lower)
+	}
+}
@@ -0,0 +1,122 @@
+package scan
+
+import (
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Totals is the recursive size and file count of a directory
+type Totals struct {
+	Size  int64
+	Files int64
+	// Dirs is the number of directories below, at any depth
+	Dirs int64
+	// Direct is the size of the files directly inside the directory
+	Direct int64
+	// ChildDirs and ChildFiles count the directory's immediate children
+	ChildDirs  int64
+	ChildFiles int64
+	// Apparent and Allocated are the total file lengths and the disk blocks
+	// in use, whatever the size provider
+	Apparent  int64
+	Allocated int64
+	// Truncated is set when the totals stop at the scan's depth limit, Depth
+	Truncated bool
+	Depth     int
+	// ModTime is the directory's modification time when it was summed. It
+	// changes with the entries directly inside, making cached totals stale.
+	ModTime time.Time
+	// ReadError is why the directory itself couldn't be listed, Unreadable
+	// the number of directories below it, itself included, that couldn't
+	ReadError  error
+	Unreadable int64
+}
+
+// Error records an entry that could not be read during a scan
+type Error struct {
+	Path string
+	Err  error
+}
+
+// DirEntry represents a directory with its size and children
+type DirEntry struct {
+	Name     string      `json:"name"`
+	Path     string      `json:"path"`
+	Size     int64       `json:"size"`
+	Percent  float64     `json:"percent"`
+	Children []*DirEntry `json:"children,omitempty"`
+	IsDir    bool        `json:"is_dir"`
+	Level    int         `json:"-"`
+	// ParentDir points back up the tree, so JSON leaves it out to avoid a cycle
+	ParentDir *DirEntry `json:"-"`
+	// Hidden entries skipped directly inside this directory
+	HiddenCount int   `json:"hidden_count,omitempty"`
+	HiddenSize  int64 `json:"hidden_size,omitempty"`
+	// ExtSizes sums the sizes of the files directly inside by lowercase extension
+	ExtSizes map[string]int64 `json:"ext_sizes,omitempty"`
+	// FileCount is the number of files below a directory (1 for a file)
+	FileCount int64 `json:"file_count"`
+	// DirCount is the number of directories below a directory, at any depth
+	DirCount int64 `json:"dir_count"`
+	// OwnSize is the size of the files directly inside a directory (Size for a file)
+	OwnSize int64 `json:"own_size"`
+	// ChildDirs and ChildFiles count a directory's immediate children
+	ChildDirs  int64 `json:"child_dirs"`
+	ChildFiles int64 `json:"child_files"`
+	// ItemCount is the number of a directory's immediate children, files and
+	// directories alike (0 for a file)
+	ItemCount int `json:"item_count"`
+	// IsSymlink is set for symbolic links, which are only entered or sized by
+	// their target with Scanner.FollowSymlinks
+	IsSymlink bool `json:"is_symlink,omitempty"`
+	// ReadError is set for directories that couldn't be listed, whose size is
+	// unknown. Unreadable counts them below a directory, itself included.
+	ReadError  error `json:"-"`
+	Unreadable int64 `json:"unreadable,omitempty"`
+	// Truncated is set when the size stops at Options.MaxDepth and leaves deeper levels out
+	Truncated bool `json:"truncated,omitempty"`
+	// Refining is set while the size is only a lower bound that the adaptive
+	// scan is still refining
+	Refining bool `json:"refining,omitempty"`
+	// Summary marks a row standing in for several entries, such as those
+	// beyond a listing limit. Scans never produce one.
+	Summary bool `json:"summary,omitempty"`
+	// MountPoint is set when another filesystem is mounted at this directory
+	MountPoint bool   `json:"mount_point,omitempty"`
+	FSType     string `json:"fs_type,omitempty"`
+	// Apparent and Allocated are the total file lengths and the disk blocks in
+	// use, whatever the size provider
+	Apparent  int64 `json:"apparent"`
+	Allocated int64 `json:"allocated"`
+	// ModTime is the last modification, of the target for followed symlinks
+	ModTime time.Time `json:"mod_time,omitzero"`
+	// Mode and Owner are only filled in for the scanned directory itself
+	Mode  fs.FileMode `json:"mode,omitempty"`
+	Owner string      `json:"owner,omitempty"`
+}
+
+// addExtSize adds a file's size to the total for its extension
+func (e *DirEntry) addExtSize(name string, size int64) {
+	ext := strings.ToLower(filepath.Ext(name))
+	if ext == "" {
+		return
+	}
+	if e.ExtSizes == nil {
+		e.ExtSizes = make(map[string]int64)
+	}
+	e.ExtSizes[ext] += size
+}
+
+// SortBySize orders entries by size (descending). Equal sizes are ordered by
+// name so the listing is stable across scans.
+func SortBySize(entries []*DirEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Size != entries[j].Size {
+			return entries[i].Size > entries[j].Size
+		}
+		return entries[i].Name < entries[j].Name
+	})
+}
@@ -0,0 +1,37 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// abortKey holds the context.CancelCauseFunc that aborts a strict scan
+type abortKey struct{}
+
+// WithErrorMode prepares ctx for a scan. In strict mode the first error
+// recorded cancels it, with that error as the cause, see Cause.
+func (s *Scanner) WithErrorMode(ctx context.Context) context.Context {
+	if s.ErrorMode != "strict" {
+		return ctx
+	}
+	ctx, abort := context.WithCancelCause(ctx)
+	return context.WithValue(ctx, abortKey{}, abort)
+}
+
+// abortScan stops the strict scan running with ctx because of err
+func abortScan(ctx context.Context, path string, err error) {
+	if abort, ok := ctx.Value(abortKey{}).(context.CancelCauseFunc); ok {
+		abort(fmt.Errorf("scan aborted at %s: %w", path, err))
+	}
+}
+
+// Cause returns the error that aborted the strict scan running with ctx,
+// or else err. The cause is not a context.Canceled, so it isn't taken for the
+// scan being cancelled by its caller.
+func Cause(ctx context.Context, err error) error {
+	if cause := context.Cause(ctx); cause != nil && !errors.Is(cause, context.Canceled) {
+		return cause
+	}
+	return err
+}
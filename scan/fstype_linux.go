@@ -0,0 +1,40 @@
+//go:build linux
+
+package scan
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// FindMount returns the device and filesystem type of the longest mount
+// point containing path, as listed in /proc/self/mounts
+func FindMount(path string) (device, fsType string) {
+	f, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return "", ""
+	}
+	defer f.Close()
+
+	var mountPoint string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 3 {
+			continue
+		}
+		// Spaces and other special characters are octal-escaped
+		mp := strings.ReplaceAll(fields[1], "\\040", " ")
+		if IsWithin(path, mp) && len(mp) >= len(mountPoint) {
+			device, fsType, mountPoint = fields[0], fields[2], mp
+		}
+	}
+	return device, fsType
+}
+
+// mountFSType returns the filesystem type (e.g. "ext4", "nfs") mounted at path
+func mountFSType(path string) string {
+	_, fsType := FindMount(path)
+	return fsType
+}
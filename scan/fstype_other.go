@@ -0,0 +1,8 @@
+//go:build !linux
+
+package scan
+
+// mountFSType is only implemented on Linux
+func mountFSType(path string) string {
+	return ""
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package scan
+
+import "io/fs"
+
+// fileID identifies a file independently of the path it was reached by
+type fileID struct{}
+
+// fileIDOf is not available on this platform, so symlinks are never followed here
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	return fileID{}, false
+}
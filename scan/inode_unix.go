@@ -0,0 +1,22 @@
+//go:build unix
+
+package scan
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// fileID identifies a file independently of the path it was reached by
+type fileID struct {
+	dev, ino uint64
+}
+
+// fileIDOf returns the identity of the file described by info
+func fileIDOf(info fs.FileInfo) (fileID, bool) {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return fileID{}, false
+	}
+	return fileID{uint64(stat.Dev), uint64(stat.Ino)}, true
+}
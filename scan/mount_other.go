@@ -0,0 +1,10 @@
+//go:build !unix
+
+package scan
+
+import "io/fs"
+
+// isMountPoint is only implemented on Unix
+func isMountPoint(dir, parent fs.FileInfo) bool {
+	return false
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package scan
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// isMountPoint reports whether dir is on a different device than its parent,
+// meaning another filesystem is mounted there
+func isMountPoint(dir, parent fs.FileInfo) bool {
+	dirStat, ok := dir.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	parentStat, ok := parent.Sys().(*syscall.Stat_t)
+	if !ok {
+		return false
+	}
+	return dirStat.Dev != parentStat.Dev
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package scan
+
+import "io/fs"
+
+// fileOwner is not available on this platform
+func fileOwner(info fs.FileInfo) string {
+	return ""
+}
@@ -0,0 +1,40 @@
+package scan
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Progress is updated by the scans running with a context from WithProgress
+// as they go, and may be read at any time meanwhile
+type Progress struct {
+	count   atomic.Int64
+	current atomic.Value // string
+}
+
+// Count returns the number of entries visited so far
+func (p *Progress) Count() int64 {
+	return p.count.Load()
+}
+
+// Current returns the directory visited last
+func (p *Progress) Current() string {
+	current, _ := p.current.Load().(string)
+	return current
+}
+
+// progressKey holds the *Progress of the scan running with a context
+type progressKey struct{}
+
+// WithProgress returns ctx carrying progress for the scans to update
+func WithProgress(ctx context.Context, progress *Progress) context.Context {
+	return context.WithValue(ctx, progressKey{}, progress)
+}
+
+// reportProgress counts entries visited in dir by the scan running with ctx
+func reportProgress(ctx context.Context, dir string, entries int) {
+	if progress, ok := ctx.Value(progressKey{}).(*Progress); ok {
+		progress.count.Add(int64(entries))
+		progress.current.Store(dir)
+	}
+}
@@ -0,0 +1,8 @@
+//go:build linux
+
+package scan
+
+// PseudoFSPaths are the kernel's virtual filesystems, which have no real size
+// and can hang a scan (e.g. /proc/kcore, /sys). Put them in
+// Scanner.ExcludedPaths to skip them.
+var PseudoFSPaths = []string{"/proc", "/sys", "/dev", "/run"}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package scan
+
+// PseudoFSPaths is only populated on Linux
+var PseudoFSPaths []string
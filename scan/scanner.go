@@ -0,0 +1,554 @@
+// Package scan sums up the sizes of directory trees. A Scanner lists a
+// directory with the recursive size of every subdirectory, and caches those
+// sizes so moving around the tree doesn't walk the same subtrees again.
+//
+//	dir, err := scan.Scan("/var", scan.Options{ShowFiles: true})
+//
+// Scanners are independent of each other: each has its own cache, settings
+// and recorded errors.
+package scan
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Scanner scans directories, caching the totals of the subdirectories it
+// sums. The exported fields are read by every scan, so they are set before
+// the first one; the settings with setters may change while scans run.
+type Scanner struct {
+	// Exclude holds the glob patterns of entry names, or of absolute paths
+	// when they contain a separator, left out of every scan
+	Exclude []string
+	// ExcludedPaths are absolute paths left out of every scan, see PseudoFSPaths
+	ExcludedPaths []string
+	// OneFileSystem keeps scans on the filesystem of the directory being
+	// scanned, except for the mount points passed to SetFollowMounts
+	OneFileSystem bool
+	// FollowSymlinks makes scans size the targets of symbolic links, entering
+	// linked directories, instead of counting the links themselves
+	FollowSymlinks bool
+	// ErrorMode decides what happens to entries that can't be read: "quiet"
+	// skips them, "warn" (the default) also records them for ErrorsUnder, and
+	// "strict" aborts the scan, see WithErrorMode
+	ErrorMode string
+
+	// followMounts are the mount points entered anyway, keyed by AbsPath
+	followMounts map[string]bool
+	// showHidden includes the entries whose names start with a dot
+	showHidden atomic.Bool
+	// provider measures every file counted, see SetSizeProvider
+	provider atomic.Pointer[SizeProvider]
+	// workers holds a token for every goroutine summing a subdirectory
+	// besides the one that started the scan, see SetWorkers
+	workers atomic.Pointer[chan struct{}]
+
+	// cache holds the totals of the directories summed so far, keyed by
+	// cacheKey, and errors the entries that couldn't be read
+	cacheMutex sync.RWMutex
+	cache      map[string]Totals
+	errors     map[string]error
+	sizeGroup  singleflight.Group
+
+	// ignored are the paths, keyed by AbsPath, left out by SetIgnored.
+	// Unlike the other excludes they change while scans are running.
+	ignoreMutex sync.RWMutex
+	ignored     map[string]string
+}
+
+// New returns a Scanner that leaves out hidden entries, measures apparent
+// sizes and sums subdirectories with one goroutine per CPU
+func New() *Scanner {
+	s := &Scanner{
+		ErrorMode: "warn",
+		cache:     make(map[string]Totals),
+		errors:    make(map[string]error),
+		ignored:   make(map[string]string),
+	}
+	s.SetWorkers(0)
+	return s
+}
+
+// Options controls what Scan and ScanDir collect
+type Options struct {
+	ShowFiles bool
+	// HiddenSummary counts and sizes the skipped hidden entries
+	HiddenSummary bool
+	// Shallow sizes uncached subdirectories by their direct files only and
+	// marks them Refining, see SizeLevels
+	Shallow bool
+	// MaxDepth limits the directory levels summed for each subdirectory (0 for all)
+	MaxDepth int
+}
+
+// Scan lists path with a new Scanner, see Scanner.Scan
+func Scan(path string, opts Options) (*DirEntry, error) {
+	return New().Scan(context.Background(), path, opts)
+}
+
+// SetShowHidden includes or leaves out the entries whose names start with a
+// dot. Totals cached before were summed the other way, see ClearCache.
+func (s *Scanner) SetShowHidden(show bool) {
+	s.showHidden.Store(show)
+}
+
+// ShowHidden reports whether hidden entries are included
+func (s *Scanner) ShowHidden() bool {
+	return s.showHidden.Load()
+}
+
+// SetFollowMounts lists the mount points that OneFileSystem scans enter anyway
+func (s *Scanner) SetFollowMounts(paths []string) {
+	s.followMounts = make(map[string]bool)
+	for _, path := range paths {
+		s.followMounts[AbsPath(path)] = true
+	}
+}
+
+// SetWorkers lets scans use up to n goroutines, or one per CPU for 0. Slow
+// network filesystems may do better with fewer.
+func (s *Scanner) SetWorkers(n int) {
+	if n <= 0 {
+		n = runtime.NumCPU()
+	}
+	workers := make(chan struct{}, n-1)
+	s.workers.Store(&workers)
+}
+
+// IsHidden reports whether the entry called name is left out as hidden
+func (s *Scanner) IsHidden(name string) bool {
+	return !s.showHidden.Load() && strings.HasPrefix(name, ".")
+}
+
+// IsExcluded reports whether the entry at path is excluded from scans. The
+// start directory itself is never passed here, so it can always be scanned.
+func (s *Scanner) IsExcluded(path string) bool {
+	if s.IsIgnored(path) {
+		return true
+	}
+	for _, excluded := range s.ExcludedPaths {
+		if path == excluded {
+			return true
+		}
+	}
+	name := filepath.Base(path)
+	for _, pattern := range s.Exclude {
+		// Patterns with a separator, such as /home/*/.cache, match whole paths
+		target := name
+		if strings.ContainsRune(pattern, filepath.Separator) {
+			target = path
+		}
+		if matched, _ := filepath.Match(pattern, target); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsIgnored reports whether path is on the ignore list
+func (s *Scanner) IsIgnored(path string) bool {
+	s.ignoreMutex.RLock()
+	defer s.ignoreMutex.RUnlock()
+	_, ok := s.ignored[AbsPath(path)]
+	return ok
+}
+
+// SetIgnored adds path to or removes it from the ignore list. The totals
+// including it stay cached, see Invalidate.
+func (s *Scanner) SetIgnored(path string, ignored bool) {
+	s.ignoreMutex.Lock()
+	defer s.ignoreMutex.Unlock()
+	if ignored {
+		s.ignored[AbsPath(path)] = path
+	} else {
+		delete(s.ignored, AbsPath(path))
+	}
+}
+
+// Ignored returns the ignored paths, sorted
+func (s *Scanner) Ignored() []string {
+	s.ignoreMutex.RLock()
+	defer s.ignoreMutex.RUnlock()
+	paths := make([]string, 0, len(s.ignored))
+	for _, path := range s.ignored {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// skipsMount reports whether the directory at path, found in a directory
+// described by parent, is a mount point that scans must not enter
+func (s *Scanner) skipsMount(path string, info, parent fs.FileInfo) bool {
+	return s.OneFileSystem && parent != nil && isMountPoint(info, parent) && !s.followMounts[AbsPath(path)]
+}
+
+// followSymlink returns the target's info for a symbolic link when
+// FollowSymlinks is set, and info otherwise. Broken links stay links.
+func (s *Scanner) followSymlink(path string, info fs.FileInfo) fs.FileInfo {
+	if !s.FollowSymlinks || info.Mode()&fs.ModeSymlink == 0 {
+		return info
+	}
+	target, err := os.Stat(path)
+	if err != nil {
+		return info
+	}
+	if _, ok := fileIDOf(target); !ok {
+		// Loops can't be detected without file ids
+		return info
+	}
+	return target
+}
+
+// Size returns cached size and file count or calculates them once, even
+// when several scans ask for the same directory at the same time. Totals cut
+// short by a cancelled ctx are returned but never cached. maxDepth limits the
+// directory levels summed (0 for all of them); totals cached with a lower
+// limit are summed again.
+func (s *Scanner) Size(ctx context.Context, path string, maxDepth int) Totals {
+	if totals, ok := s.CachedTotals(path, maxDepth); ok {
+		return totals
+	}
+
+	for {
+		// Concurrent callers for the same directory share one calculation
+		result, err, _ := s.sizeGroup.Do(cacheKey(path), func() (any, error) {
+			// Calculate size with full recursion (but only once)
+			totals := s.FullDirSize(ctx, path, maxDepth, nil)
+			if err := ctx.Err(); err != nil {
+				return totals, err
+			}
+
+			// A directory removed while it was being sized must not leave a stale entry behind
+			if _, err := os.Lstat(path); errors.Is(err, fs.ErrNotExist) {
+				return Totals{}, nil
+			}
+
+			s.Store(path, totals)
+			return totals, nil
+		})
+		if err == nil || ctx.Err() != nil {
+			return result.(Totals)
+		}
+		// The caller doing the calculation was cancelled, but this one wasn't
+	}
+}
+
+// CachedTotals returns the cached totals of path if they are good for a scan
+// limited to maxDepth levels and the directory wasn't modified since they were
+// summed. Changes further down don't show in its modification time; only
+// Invalidate or a new Scanner picks those up.
+func (s *Scanner) CachedTotals(path string, maxDepth int) (Totals, bool) {
+	s.cacheMutex.RLock()
+	totals, exists := s.cache[cacheKey(path)]
+	s.cacheMutex.RUnlock()
+	if !exists || !coversDepth(totals, maxDepth) {
+		return totals, false
+	}
+	info, err := os.Stat(path)
+	return totals, err == nil && info.ModTime().Equal(totals.ModTime)
+}
+
+// Store caches the totals of path, such as those passed to the visit
+// function of FullDirSize
+func (s *Scanner) Store(path string, totals Totals) {
+	s.cacheMutex.Lock()
+	s.cache[cacheKey(path)] = totals
+	s.cacheMutex.Unlock()
+}
+
+// Invalidate drops the cached totals of path, of everything below it and of
+// its ancestors, whose totals include it
+func (s *Scanner) Invalidate(path string) {
+	key := cacheKey(path)
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	for cached := range s.cache {
+		if IsWithin(cached, key) || IsWithin(key, cached) {
+			delete(s.cache, cached)
+		}
+	}
+}
+
+// ClearCache drops every cached total
+func (s *Scanner) ClearCache() {
+	s.cacheMutex.Lock()
+	defer s.cacheMutex.Unlock()
+	clear(s.cache)
+}
+
+// FullDirSize does full recursive calculation of size and file count, or
+// sums up at most maxDepth directory levels, path itself being the first,
+// when maxDepth is positive. If visit is non-nil it is called with the totals
+// of every subdirectory below path. The walk stops early once ctx is cancelled.
+func (s *Scanner) FullDirSize(ctx context.Context, path string, maxDepth int, visit func(path string, totals Totals)) Totals {
+	totals, complete := s.sizeDir(ctx, path, maxDepth-1, visit)
+	if !complete && ctx.Err() == nil {
+		totals.Truncated = true
+		totals.Depth = maxDepth
+	}
+	return totals
+}
+
+// coversDepth reports whether totals summed so far are good for a scan
+// limited to maxDepth levels (0 for unlimited)
+func coversDepth(totals Totals, maxDepth int) bool {
+	return !totals.Truncated || (maxDepth > 0 && totals.Depth >= maxDepth)
+}
+
+// AbsPath returns the cleaned absolute form of path, so "." and the working
+// directory, or "a/../b" and "b", are scanned and cached as one directory
+func AbsPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return filepath.Clean(path)
+	}
+	return abs
+}
+
+// IsWithin reports whether path is dir or lies below it
+func IsWithin(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// recordError remembers why path could not be read, or aborts the scan
+// running with ctx, depending on ErrorMode
+func (s *Scanner) recordError(ctx context.Context, path string, err error) {
+	// Entries deleted between listing a directory and reading them are
+	// expected on a live system; they are skipped without counting them
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	switch s.ErrorMode {
+	case "quiet":
+		return
+	case "strict":
+		abortScan(ctx, path, err)
+	}
+	s.cacheMutex.Lock()
+	s.errors[path] = err
+	s.cacheMutex.Unlock()
+}
+
+// ErrorsUnder returns the recorded errors for root and everything below it, sorted by path
+func (s *Scanner) ErrorsUnder(root string) []Error {
+	prefix := root
+	if !strings.HasSuffix(prefix, string(filepath.Separator)) {
+		prefix += string(filepath.Separator)
+	}
+
+	s.cacheMutex.RLock()
+	var errs []Error
+	for path, err := range s.errors {
+		if path == root || strings.HasPrefix(path, prefix) {
+			errs = append(errs, Error{path, err})
+		}
+	}
+	s.cacheMutex.RUnlock()
+
+	sort.Slice(errs, func(i, j int) bool {
+		return errs[i].Path < errs[j].Path
+	})
+	return errs
+}
+
+// Scan lists the directory at path with the totals of its subdirectories,
+// as the root of a tree. A file is returned on its own.
+func (s *Scanner) Scan(ctx context.Context, path string, opts Options) (*DirEntry, error) {
+	dir, err := s.ScanDir(ctx, path, nil, 0, opts)
+	if err != nil {
+		return nil, err
+	}
+	dir.Percent = 100.0
+	return dir, nil
+}
+
+// ScanDir scans directory using cached sizes when possible, as a child of
+// parentDir at the given level of the tree
+func (s *Scanner) ScanDir(ctx context.Context, path string, parentDir *DirEntry, level int, opts Options) (*DirEntry, error) {
+	path = AbsPath(path)
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &DirEntry{
+		Name:      filepath.Base(path),
+		Path:      path,
+		IsDir:     info.IsDir(),
+		Level:     level,
+		ParentDir: parentDir,
+		Mode:      info.Mode(),
+		ModTime:   info.ModTime(),
+		Owner:     fileOwner(info),
+	}
+
+	if !info.IsDir() {
+		entry.Size = s.fileSize(path, info)
+		entry.OwnSize = entry.Size
+		entry.Apparent = info.Size()
+		entry.Allocated = AllocatedSize{}.FileSize(path, info)
+		entry.FileCount = 1
+		return entry, nil
+	}
+
+	// For directories, scan immediate children only
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return nil, err
+	}
+	reportProgress(ctx, path, len(entries))
+
+	var totalSize int64
+	var directories []*DirEntry
+	var files []*DirEntry
+
+	for _, e := range entries {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		childPath := filepath.Join(path, e.Name())
+		if s.IsExcluded(childPath) {
+			continue
+		}
+
+		if s.IsHidden(e.Name()) {
+			if opts.HiddenSummary {
+				entry.HiddenCount++
+				if e.IsDir() {
+					entry.HiddenSize += s.Size(ctx, childPath, opts.MaxDepth).Size
+				} else if hiddenInfo, err := e.Info(); err == nil {
+					entry.HiddenSize += s.fileSize(childPath, hiddenInfo)
+				}
+			}
+			continue
+		}
+
+		childInfo, err := e.Info()
+		if err != nil {
+			s.recordError(ctx, childPath, err)
+			continue
+		}
+		isSymlink := childInfo.Mode()&fs.ModeSymlink != 0
+		childInfo = s.followSymlink(childPath, childInfo)
+
+		if childInfo.IsDir() {
+			// Use cached size (calculated with full recursion when first needed)
+			var childTotals Totals
+			var refining bool
+			if s.skipsMount(childPath, childInfo, info) {
+				// Left out, see OneFileSystem
+			} else if opts.Shallow {
+				childTotals, refining = s.shallowSize(ctx, childPath, opts.MaxDepth)
+			} else {
+				childTotals = s.Size(ctx, childPath, opts.MaxDepth)
+			}
+
+			child := &DirEntry{
+				Name:       e.Name(),
+				Path:       childPath,
+				Size:       childTotals.Size,
+				OwnSize:    childTotals.Direct,
+				FileCount:  childTotals.Files,
+				DirCount:   childTotals.Dirs,
+				ChildDirs:  childTotals.ChildDirs,
+				ChildFiles: childTotals.ChildFiles,
+				ItemCount:  int(childTotals.ChildDirs + childTotals.ChildFiles),
+				ModTime:    childInfo.ModTime(),
+				Apparent:   childTotals.Apparent,
+				Allocated:  childTotals.Allocated,
+				IsDir:      true,
+				Level:      level + 1,
+				ParentDir:  entry,
+				Refining:   refining,
+				Truncated:  childTotals.Truncated,
+				IsSymlink:  isSymlink,
+				ReadError:  childTotals.ReadError,
+				Unreadable: childTotals.Unreadable,
+			}
+			if isMountPoint(childInfo, info) {
+				child.MountPoint = true
+				child.FSType = mountFSType(childPath)
+			}
+			directories = append(directories, child)
+			totalSize += childTotals.Size
+			entry.FileCount += childTotals.Files
+			entry.DirCount += childTotals.Dirs + 1
+			entry.Apparent += childTotals.Apparent
+			entry.Allocated += childTotals.Allocated
+			entry.Unreadable += childTotals.Unreadable
+			entry.ChildDirs++
+		} else if opts.ShowFiles {
+			size := s.fileSize(childPath, childInfo)
+			entry.addExtSize(e.Name(), size)
+			child := &DirEntry{
+				Name:      e.Name(),
+				Path:      childPath,
+				Size:      size,
+				OwnSize:   size,
+				Apparent:  childInfo.Size(),
+				Allocated: AllocatedSize{}.FileSize(childPath, childInfo),
+				IsSymlink: isSymlink,
+				ModTime:   childInfo.ModTime(),
+				FileCount: 1,
+				IsDir:     false,
+				Level:     level + 1,
+				ParentDir: entry,
+			}
+			files = append(files, child)
+			totalSize += size
+			entry.OwnSize += size
+			entry.Apparent += child.Apparent
+			entry.Allocated += child.Allocated
+			entry.FileCount++
+			entry.ChildFiles++
+		} else {
+			size := s.fileSize(childPath, childInfo)
+			entry.addExtSize(e.Name(), size)
+			totalSize += size
+			entry.OwnSize += size
+			entry.Apparent += childInfo.Size()
+			entry.Allocated += AllocatedSize{}.FileSize(childPath, childInfo)
+			entry.FileCount++
+			entry.ChildFiles++
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		// Sizes below may be partial
+		return nil, err
+	}
+
+	// Sort directories by size (descending)
+	SortBySize(directories)
+
+	// Sort files by size (descending)
+	SortBySize(files)
+
+	entry.Children = append(entry.Children, directories...)
+	entry.Children = append(entry.Children, files...)
+
+	entry.Size = totalSize
+	entry.ItemCount = int(entry.ChildDirs + entry.ChildFiles)
+
+	// Calculate percentages
+	if totalSize > 0 {
+		for _, child := range entry.Children {
+			child.Percent = float64(child.Size) / float64(totalSize) * 100
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,170 @@
+package scan
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// writeTree creates the files under root, each path with the given size in
+// bytes, along with the directories they are in
+func writeTree(t testing.TB, root string, files map[string]int) {
+	t.Helper()
+	for name, size := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// slowSize measures apparent sizes, counting the files it is asked about and
+// taking a while for each so concurrent scans overlap
+type slowSize struct {
+	calls atomic.Int64
+}
+
+func (p *slowSize) FileSize(path string, info fs.FileInfo) int64 {
+	p.calls.Add(1)
+	time.Sleep(time.Millisecond)
+	return info.Size()
+}
+
+func TestSizeSharesConcurrentCalculation(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]int{
+		"a.txt":     100,
+		"b/c.txt":   200,
+		"b/d.txt":   300,
+		"b/e/f.txt": 400,
+		"g/h.txt":   500,
+	}
+	writeTree(t, root, files)
+	// Every directory and file is visited once by a single calculation
+	const entries = 8
+
+	s := New()
+	provider := &slowSize{}
+	s.SetSizeProvider(provider)
+	var progress Progress
+	ctx := WithProgress(context.Background(), &progress)
+
+	const callers = 8
+	var start, done sync.WaitGroup
+	start.Add(1)
+	sizes := make([]int64, callers)
+	for i := range callers {
+		done.Add(1)
+		go func() {
+			defer done.Done()
+			start.Wait()
+			sizes[i] = s.Size(ctx, root, 0).Size
+		}()
+	}
+	start.Done()
+	done.Wait()
+
+	for i, size := range sizes {
+		if size != 1500 {
+			t.Errorf("caller %d got size %d, want 1500", i, size)
+		}
+	}
+	if got := provider.calls.Load(); got != int64(len(files)) {
+		t.Errorf("files measured %d times, want %d (once each)", got, len(files))
+	}
+	if got := progress.Count(); got != entries {
+		t.Errorf("entries listed %d times, want %d (one pass)", got, entries)
+	}
+}
+
+// removingSize measures apparent sizes and removes dir once it is asked about
+// the file trigger, standing in for another process deleting it mid-scan
+type removingSize struct {
+	trigger, dir string
+}
+
+func (p removingSize) FileSize(path string, info fs.FileInfo) int64 {
+	if filepath.Base(path) == p.trigger {
+		os.RemoveAll(p.dir)
+	}
+	return info.Size()
+}
+
+func TestSizeSkipsDirectoryRemovedDuringScan(t *testing.T) {
+	cases := []struct {
+		name    string
+		trigger string
+	}{
+		// Removed after the root was listed, before the entry is looked at
+		{"before stat", "a.txt"},
+		// Removed after it was looked at, before it is listed itself
+		{"before listing", "c.txt"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			root := t.TempDir()
+			writeTree(t, root, map[string]int{
+				"a.txt":   100,
+				"b/c.txt": 200,
+				"z/y.txt": 400,
+				"z/x/w":   800,
+			})
+
+			s := New()
+			// Sequential, so the directories are summed in listing order
+			s.SetWorkers(1)
+			s.SetSizeProvider(removingSize{c.trigger, filepath.Join(root, "z")})
+			totals := s.Size(context.Background(), root, 0)
+
+			if errs := s.ErrorsUnder(root); len(errs) != 0 {
+				t.Errorf("errors recorded: %v", errs)
+			}
+			if totals.Size != 300 || totals.Files != 2 {
+				t.Errorf("got %d bytes in %d files, want 300 bytes in 2 files", totals.Size, totals.Files)
+			}
+		})
+	}
+}
+
+func TestRelativePathsShareCache(t *testing.T) {
+	root := t.TempDir()
+	writeTree(t, root, map[string]int{
+		"work/a.txt":   100,
+		"work/b/c.txt": 200,
+	})
+	work := filepath.Join(root, "work")
+	t.Chdir(work)
+
+	s := New()
+	provider := &slowSize{}
+	s.SetSizeProvider(provider)
+	if size := s.Size(context.Background(), ".", 0).Size; size != 300 {
+		t.Fatalf("size of . is %d, want 300", size)
+	}
+	measured := provider.calls.Load()
+
+	for _, path := range []string{work, "../work", "b/..", "./"} {
+		if _, ok := s.CachedTotals(path, 0); !ok {
+			t.Errorf("%s not cached after sizing .", path)
+		}
+		if size := s.Size(context.Background(), path, 0).Size; size != 300 {
+			t.Errorf("size of %s is %d, want 300", path, size)
+		}
+	}
+	if got := provider.calls.Load(); got != measured {
+		t.Errorf("files measured %d times, want %d (only for .)", got, measured)
+	}
+
+	s.Size(context.Background(), "b", 0)
+	if _, ok := s.CachedTotals(filepath.Join(work, "b"), 0); !ok {
+		t.Error("b not cached by its absolute path after sizing it as b")
+	}
+}
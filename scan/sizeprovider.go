@@ -0,0 +1,50 @@
+package scan
+
+import "io/fs"
+
+// SizeProvider measures the space a file takes up. The scanner asks it for
+// every file it counts, so filesystems where neither the apparent size nor the
+// allocated blocks tell the truth (deduplicated or compressed ZFS and Btrfs
+// datasets) can plug in one that queries the real usage.
+type SizeProvider interface {
+	FileSize(path string, info fs.FileInfo) int64
+}
+
+// ApparentSize is the file length as reported by stat
+type ApparentSize struct{}
+
+func (ApparentSize) FileSize(path string, info fs.FileInfo) int64 {
+	return info.Size()
+}
+
+// SizeProviders are the providers selectable by name
+var SizeProviders = map[string]SizeProvider{
+	"apparent":  ApparentSize{},
+	"allocated": AllocatedSize{},
+}
+
+// RegisterSizeProvider makes provider selectable under name. Call it from an
+// init function in the file that implements the provider.
+func RegisterSizeProvider(name string, provider SizeProvider) {
+	SizeProviders[name] = provider
+}
+
+// SetSizeProvider makes provider measure the files of the following scans.
+// Totals cached before were measured the other way, see ClearCache.
+func (s *Scanner) SetSizeProvider(provider SizeProvider) {
+	s.provider.Store(&provider)
+}
+
+// SizeProvider returns the provider measuring files, ApparentSize unless
+// another one was set
+func (s *Scanner) SizeProvider() SizeProvider {
+	if provider := s.provider.Load(); provider != nil && *provider != nil {
+		return *provider
+	}
+	return ApparentSize{}
+}
+
+// fileSize returns the size of the file at path according to the selected provider
+func (s *Scanner) fileSize(path string, info fs.FileInfo) int64 {
+	return s.SizeProvider().FileSize(path, info)
+}
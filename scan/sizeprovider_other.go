@@ -0,0 +1,12 @@
+//go:build !unix
+
+package scan
+
+import "io/fs"
+
+// AllocatedSize falls back to the apparent size where block counts aren't available
+type AllocatedSize struct{}
+
+func (AllocatedSize) FileSize(path string, info fs.FileInfo) int64 {
+	return info.Size()
+}
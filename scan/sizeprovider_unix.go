@@ -0,0 +1,21 @@
+//go:build unix
+
+package scan
+
+import (
+	"io/fs"
+	"syscall"
+)
+
+// AllocatedSize is the space of the blocks allocated to the file, which is
+// smaller than its length for sparse files
+type AllocatedSize struct{}
+
+func (AllocatedSize) FileSize(path string, info fs.FileInfo) int64 {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return info.Size()
+	}
+	// st_blocks is always counted in 512-byte units
+	return int64(stat.Blocks) * 512
+}
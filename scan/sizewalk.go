@@ -0,0 +1,233 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// sizeDir sums up path, descending at most depth directory levels below it
+// (all of them when depth is negative). complete reports whether nothing was
+// left out because of the depth limit. Subdirectories are summed in parallel
+// by up to SetWorkers goroutines; visit is never called concurrently.
+func (s *Scanner) sizeDir(ctx context.Context, path string, depth int, visit func(path string, totals Totals)) (totals Totals, complete bool) {
+	w := s.newWalk(ctx)
+	w.visit = visit
+	return w.run(path, depth)
+}
+
+// WalkFiles calls visit with every file counted below path and its size, in
+// no particular order but never concurrently. The walk stops early once ctx
+// is cancelled.
+func (s *Scanner) WalkFiles(ctx context.Context, path string, visit func(path string, size int64)) {
+	w := s.newWalk(ctx)
+	w.visitFile = visit
+	w.run(path, -1)
+}
+
+// newWalk prepares a walk with the Scanner's current workers
+func (s *Scanner) newWalk(ctx context.Context) *sizeWalk {
+	return &sizeWalk{Scanner: s, ctx: ctx, workers: *s.workers.Load()}
+}
+
+// run does the work of sizeDir with the callbacks set in w
+func (w *sizeWalk) run(path string, depth int) (totals Totals, complete bool) {
+	// Taken before reading, so changes made meanwhile make the totals stale
+	info, err := os.Stat(path)
+
+	if w.FollowSymlinks {
+		w.seen = make(map[fileID]bool)
+		if err == nil {
+			if id, ok := fileIDOf(info); ok {
+				w.seen[id] = true
+			}
+		}
+	}
+	totals, complete = w.tree(path, depth)
+	w.repanic()
+	if err == nil {
+		totals.ModTime = info.ModTime()
+	}
+	return totals, complete
+}
+
+// sizeWalk is the state shared by the goroutines of one sizeDir call
+type sizeWalk struct {
+	*Scanner
+	ctx   context.Context
+	visit func(path string, totals Totals)
+	// visitFile, if set, is called with every file counted and its size
+	visitFile func(path string, size int64)
+	// workers is the Scanner's pool when the walk started
+	workers chan struct{}
+
+	mu sync.Mutex
+	// seen holds the directories already summed when symlinks are followed,
+	// so a link back up the tree or to a directory counted before isn't
+	// entered again
+	seen map[fileID]bool
+	// panicked is the first panic recovered in a worker, raised again by
+	// the caller so it gets reported like any other scan panic
+	panicked any
+}
+
+// subdirSize is a subdirectory to sum and, once summed, its totals
+type subdirSize struct {
+	path     string
+	info     fs.FileInfo
+	totals   Totals
+	complete bool
+}
+
+// tree sums up path, depth levels deep. Its subdirectories are summed by
+// workers while some are free and by the calling goroutine otherwise, and
+// added up in listing order, so the totals match those of a sequential walk.
+func (w *sizeWalk) tree(path string, depth int) (totals Totals, complete bool) {
+	ctx := w.ctx
+	if ctx.Err() != nil {
+		return totals, false
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		w.recordError(ctx, path, err)
+		if !errors.Is(err, fs.ErrNotExist) {
+			totals.ReadError, totals.Unreadable = err, 1
+		}
+		return totals, true
+	}
+	reportProgress(ctx, path, len(entries))
+	complete = true
+
+	// Mount points are only detected when they have to be skipped
+	var dirInfo fs.FileInfo
+	if w.OneFileSystem {
+		dirInfo, _ = os.Stat(path)
+	}
+
+	var subdirs []subdirSize
+	for _, entry := range entries {
+		childPath := filepath.Join(path, entry.Name())
+		if w.IsHidden(entry.Name()) || w.IsExcluded(childPath) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			w.recordError(ctx, childPath, err)
+			continue
+		}
+		info = w.followSymlink(childPath, info)
+
+		if info.IsDir() {
+			totals.ChildDirs++
+			if w.skipsMount(childPath, info, dirInfo) {
+				continue
+			}
+			if depth == 0 {
+				complete = false
+				continue
+			}
+			if !w.firstVisit(info) {
+				continue
+			}
+			subdirs = append(subdirs, subdirSize{path: childPath, info: info})
+		} else {
+			size := w.fileSize(childPath, info)
+			totals.Size += size
+			totals.Direct += size
+			totals.Files++
+			totals.ChildFiles++
+			totals.Apparent += info.Size()
+			totals.Allocated += AllocatedSize{}.FileSize(childPath, info)
+			if w.visitFile != nil {
+				w.mu.Lock()
+				w.visitFile(childPath, size)
+				w.mu.Unlock()
+			}
+		}
+	}
+
+	var wg sync.WaitGroup
+	for i := range subdirs {
+		sub := &subdirs[i]
+		if !w.acquireWorker() {
+			w.subdir(sub, depth)
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer w.releaseWorker()
+			defer w.catchPanic()
+			w.subdir(sub, depth)
+		}()
+	}
+	wg.Wait()
+
+	totals.Dirs = totals.ChildDirs
+	for _, sub := range subdirs {
+		totals.Size += sub.totals.Size
+		totals.Files += sub.totals.Files
+		totals.Dirs += sub.totals.Dirs
+		totals.Apparent += sub.totals.Apparent
+		totals.Allocated += sub.totals.Allocated
+		totals.Unreadable += sub.totals.Unreadable
+		complete = complete && sub.complete
+	}
+	return totals, complete
+}
+
+// subdir sums up sub, a subdirectory of a directory summed depth levels deep
+func (w *sizeWalk) subdir(sub *subdirSize, depth int) {
+	sub.totals, sub.complete = w.tree(sub.path, depth-1) // Recursive call
+	sub.totals.ModTime = sub.info.ModTime()
+	if w.visit != nil {
+		w.mu.Lock()
+		w.visit(sub.path, sub.totals)
+		w.mu.Unlock()
+	}
+}
+
+// firstVisit reports whether the directory described by info should be
+// summed, which is always unless symlinks are followed and it was reached before
+func (w *sizeWalk) firstVisit(info fs.FileInfo) bool {
+	if w.seen == nil {
+		return true
+	}
+	id, ok := fileIDOf(info)
+	if !ok {
+		return false
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.seen[id] {
+		return false
+	}
+	w.seen[id] = true
+	return true
+}
+
+// catchPanic keeps the first panic of a worker for repanic
+func (w *sizeWalk) catchPanic() {
+	if r := recover(); r != nil {
+		w.mu.Lock()
+		if w.panicked == nil {
+			w.panicked = r
+		}
+		w.mu.Unlock()
+	}
+}
+
+// repanic raises a panic recovered in a worker in the calling goroutine
+func (w *sizeWalk) repanic() {
+	w.mu.Lock()
+	r := w.panicked
+	w.mu.Unlock()
+	if r != nil {
+		panic(r)
+	}
+}
@@ -0,0 +1,93 @@
+package scan
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeSyntheticTree fills root with breadth subdirectories per directory,
+// depth levels deep, and files small files in every directory
+func writeSyntheticTree(b *testing.B, root string, breadth, depth, files int) {
+	b.Helper()
+	for i := range files {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%d", i)), make([]byte, 100*i), 0o644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if depth == 0 {
+		return
+	}
+	for i := range breadth {
+		dir := filepath.Join(root, fmt.Sprintf("d%d", i))
+		if err := os.Mkdir(dir, 0o755); err != nil {
+			b.Fatal(err)
+		}
+		writeSyntheticTree(b, dir, breadth, depth-1, files)
+	}
+}
+
+func BenchmarkSizeDir(b *testing.B) {
+	shapes := []struct {
+		name                  string
+		breadth, depth, files int
+	}{
+		{"wide", 24, 2, 10},
+		{"deep", 2, 8, 10},
+	}
+	workers := []struct {
+		name string
+		n    int
+	}{
+		{"sequential", 1},
+		{"parallel", 0},
+	}
+	for _, shape := range shapes {
+		root := b.TempDir()
+		writeSyntheticTree(b, root, shape.breadth, shape.depth, shape.files)
+		for _, w := range workers {
+			b.Run(shape.name+"/"+w.name, func(b *testing.B) {
+				s := New()
+				s.SetWorkers(w.n)
+				b.ReportAllocs()
+				for b.Loop() {
+					s.sizeDir(context.Background(), root, -1, nil)
+				}
+			})
+		}
+	}
+}
+
+func BenchmarkPrime(b *testing.B) {
+	shapes := []struct {
+		name                  string
+		breadth, depth, files int
+	}{
+		{"wide", 24, 2, 10},
+		{"deep", 2, 8, 10},
+	}
+	sizers := []struct {
+		name string
+		size func(s *Scanner, root string)
+	}{
+		{"prime", func(s *Scanner, root string) { s.Prime(context.Background(), root) }},
+		{"size", func(s *Scanner, root string) { s.Size(context.Background(), root, 0) }},
+	}
+	for _, shape := range shapes {
+		root := b.TempDir()
+		writeSyntheticTree(b, root, shape.breadth, shape.depth, shape.files)
+		for _, sizer := range sizers {
+			b.Run(shape.name+"/"+sizer.name, func(b *testing.B) {
+				s := New()
+				b.ReportAllocs()
+				for b.Loop() {
+					// Both return at once for a cached root
+					s.ClearCache()
+					sizer.size(s, root)
+				}
+			})
+		}
+	}
+}
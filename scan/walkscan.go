@@ -0,0 +1,133 @@
+package scan
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Prime sizes every directory below root in a single filepath.WalkDir pass
+// and caches the results, so the scan that follows never has to re-walk a
+// subtree. Nothing is walked when root itself is cached already; below it,
+// every directory is walked and cached again. Nothing is cached if ctx is
+// cancelled before the walk finishes. The walk has no depth limit and never
+// follows symlinks, so it is of no use to scans with either.
+func (s *Scanner) Prime(ctx context.Context, root string) {
+	root = AbsPath(root)
+	if _, ok := s.CachedTotals(root, 0); ok {
+		return
+	}
+
+	// Sizes and counts of the files directly inside each directory
+	sizes := make(map[string]Totals)
+
+	filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if ctx.Err() != nil {
+			return fs.SkipAll
+		}
+		if err != nil {
+			s.recordError(ctx, path, err)
+			if d != nil && d.IsDir() && !errors.Is(err, fs.ErrNotExist) {
+				// The directory was registered before it failed to be listed
+				totals := sizes[path]
+				totals.ReadError, totals.Unreadable = err, 1
+				sizes[path] = totals
+			}
+			if d != nil && d.IsDir() && path != root {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if path != root && (s.IsHidden(d.Name()) || s.IsExcluded(path)) {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() && path != root && s.OneFileSystem {
+			info, err := d.Info()
+			parent, parentErr := os.Lstat(filepath.Dir(path))
+			if err == nil && parentErr == nil && s.skipsMount(path, info, parent) {
+				// Counted as a child, but its contents stay out of the totals
+				totals := sizes[filepath.Dir(path)]
+				totals.ChildDirs++
+				sizes[filepath.Dir(path)] = totals
+				return fs.SkipDir
+			}
+		}
+
+		reportProgress(ctx, filepath.Dir(path), 1)
+		if d.IsDir() {
+			// Register the directory so empty ones still get a cache entry
+			totals := sizes[path]
+			if info, err := d.Info(); err == nil {
+				totals.ModTime = info.ModTime()
+			}
+			sizes[path] = totals
+			if path != root {
+				parent := sizes[filepath.Dir(path)]
+				parent.ChildDirs++
+				sizes[filepath.Dir(path)] = parent
+			}
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			s.recordError(ctx, path, err)
+			return nil
+		}
+		totals := sizes[filepath.Dir(path)]
+		totals.Size += s.fileSize(path, info)
+		totals.Apparent += info.Size()
+		totals.Allocated += AllocatedSize{}.FileSize(path, info)
+		totals.Files++
+		totals.ChildFiles++
+		sizes[filepath.Dir(path)] = totals
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return
+	}
+
+	// Only direct files and subdirectories have been counted so far
+	for dir, totals := range sizes {
+		totals.Direct = totals.Size
+		totals.Dirs = totals.ChildDirs
+		sizes[dir] = totals
+	}
+
+	// Fold each directory into its parent, deepest first
+	dirs := make([]string, 0, len(sizes))
+	for dir := range sizes {
+		dirs = append(dirs, dir)
+	}
+	sort.Slice(dirs, func(i, j int) bool {
+		return strings.Count(dirs[i], string(filepath.Separator)) > strings.Count(dirs[j], string(filepath.Separator))
+	})
+	for _, dir := range dirs {
+		if dir != root {
+			parent := sizes[filepath.Dir(dir)]
+			parent.Size += sizes[dir].Size
+			parent.Files += sizes[dir].Files
+			parent.Dirs += sizes[dir].Dirs
+			parent.Apparent += sizes[dir].Apparent
+			parent.Allocated += sizes[dir].Allocated
+			parent.Unreadable += sizes[dir].Unreadable
+			sizes[filepath.Dir(dir)] = parent
+		}
+	}
+
+	s.cacheMutex.Lock()
+	for dir, totals := range sizes {
+		s.cache[cacheKey(dir)] = totals
+	}
+	s.cacheMutex.Unlock()
+}
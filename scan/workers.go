@@ -0,0 +1,17 @@
+package scan
+
+// acquireWorker takes a token if one is free, without waiting. Without free
+// tokens directories are summed sequentially.
+func (w *sizeWalk) acquireWorker() bool {
+	select {
+	case w.workers <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// releaseWorker returns a token taken by acquireWorker
+func (w *sizeWalk) releaseWorker() {
+	<-w.workers
+}
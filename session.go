@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Session is the navigation state saved under a name, to pick up an audit
+// where it was left
+type Session struct {
+	StartPath string   `json:"start_path"`
+	Path      string   `json:"path"`
+	Selected  string   `json:"selected"`
+	Expanded  []string `json:"expanded"`
+	ShowAllIn []string `json:"show_all_in"`
+
+	TreeMode        bool   `json:"tree_mode"`
+	ShowFiles       bool   `json:"show_files"`
+	SortMode        string `json:"sort_mode"`
+	RootRelative    bool   `json:"root_relative"`
+	HomeRelative    bool   `json:"home_relative"`
+	ShowCounts      bool   `json:"show_counts"`
+	ShowBar         bool   `json:"show_bar"`
+	ShowAllocated   bool   `json:"show_allocated"`
+	ItemCounts      string `json:"item_counts"`
+	PercentDecimals int    `json:"percent_decimals"`
+}
+
+// sessionPath returns where the session called name is stored, in the
+// sessions directory next to the config file
+func sessionPath(name string) (string, error) {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return "", fmt.Errorf("invalid session name %q", name)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "usage", "sessions", name+".json"), nil
+}
+
+// loadSession reads the session called name. A session that wasn't saved yet
+// is reported as fs.ErrNotExist.
+func loadSession(name string) (Session, error) {
+	var s Session
+	path, err := sessionPath(name)
+	if err != nil {
+		return s, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, fmt.Errorf("%s: %v", path, err)
+	}
+	return s, nil
+}
+
+// saveSession writes the model's navigation state as the session called name
+func saveSession(name string, m Model) error {
+	path, err := sessionPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(m.session(), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// session captures the model's navigation state
+func (m Model) session() Session {
+	s := Session{
+		StartPath:       m.StartPath,
+		TreeMode:        m.TreeMode,
+		ShowFiles:       m.ShowFiles,
+		SortMode:        sortModeNames[m.SortMode],
+		RootRelative:    m.RootRelative,
+		HomeRelative:    m.HomeRelative,
+		ShowCounts:      m.ShowCounts,
+		ShowBar:         m.ShowBar,
+		ShowAllocated:   m.ShowAllocated,
+		ItemCounts:      itemCountNames[m.ItemCounts],
+		PercentDecimals: m.PercentDecimals,
+	}
+	if m.RootDir != nil {
+		s.Path = m.RootDir.Path
+	}
+	if entry := m.selectedEntry(); entry != nil && !m.ShowTopDirs {
+		s.Selected = entry.Path
+	}
+	for path := range m.Expanded {
+		s.Expanded = append(s.Expanded, path)
+	}
+	sort.Strings(s.Expanded)
+	for path := range m.ShowAllIn {
+		s.ShowAllIn = append(s.ShowAllIn, path)
+	}
+	sort.Strings(s.ShowAllIn)
+	return s
+}
+
+// applySettings restores the session's display settings, before the first scan
+func (s Session) applySettings(m *Model) {
+	m.TreeMode = s.TreeMode
+	m.ShowFiles = s.ShowFiles
+	for mode, name := range sortModeNames {
+		if name == s.SortMode {
+			m.SortMode = SortMode(mode)
+		}
+	}
+	m.RootRelative = s.RootRelative
+	m.HomeRelative = s.HomeRelative
+	m.ShowCounts = s.ShowCounts
+	m.ShowBar = s.ShowBar
+	m.ShowAllocated = s.ShowAllocated
+	for mode, name := range itemCountNames {
+		if name == s.ItemCounts {
+			m.ItemCounts = ItemCounts(mode)
+		}
+	}
+	if s.PercentDecimals >= 0 && s.PercentDecimals <= maxPercentDecimals {
+		m.PercentDecimals = s.PercentDecimals
+	}
+}
+
+// restoreView returns to the session's directory after the first scan,
+// expands what was expanded and selects what was selected. Paths that are
+// gone by now are skipped.
+func (s Session) restoreView(ctx context.Context, m *Model) error {
+	if s.Path != "" && s.Path != m.RootDir.Path {
+		dir, err := scanner.Scan(ctx, s.Path, m.scanOptions())
+		if err == nil {
+			m.RootDir = dir
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+
+	m.ShowAllIn = make(map[string]bool)
+	for _, path := range s.ShowAllIn {
+		m.ShowAllIn[path] = true
+	}
+
+	// Parents sort before their subdirectories, so each is listed by the time it is expanded
+	for _, path := range s.Expanded {
+		m.updateVisibleDirs()
+		for _, entry := range m.VisibleDirs {
+			if entry.Path != path || !entry.IsDir || isParentEntry(entry) {
+				continue
+			}
+			dir, err := scanner.ScanDir(ctx, path, entry.ParentDir, entry.Level, m.scanOptions())
+			if err != nil {
+				break
+			}
+			for _, child := range dir.Children {
+				child.ParentDir = entry
+			}
+			entry.Children = dir.Children
+			m.setExpanded(path)
+			break
+		}
+	}
+
+	m.updateVisibleDirs()
+	if m.selectPath(s.Selected) {
+		m.ensureCursorVisible()
+	}
+	return nil
+}
+
+// promptSaveSession asks for the name to save the session under, offering the current one
+func (m *Model) promptSaveSession() {
+	m.Input = &InputPrompt{
+		Prompt: "Save session as: ",
+		Value:  m.SessionName,
+		OnSubmit: func(m *Model, name string) tea.Cmd {
+			name = strings.TrimSpace(name)
+			if err := saveSession(name, *m); err != nil {
+				m.Status = fmt.Sprintf("Session not saved: %v", err)
+				return nil
+			}
+			m.SessionName = name
+			m.Status = fmt.Sprintf("Saved session %s", name)
+			return nil
+		},
+	}
+}
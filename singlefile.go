@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/dustin/go-humanize"
+)
+
+// singleFile reports whether the start path, or a path returned to from the
+// history, is a file rather than a directory
+func (m Model) singleFile() bool {
+	return m.RootDir != nil && !m.RootDir.IsDir
+}
+
+// filePanel describes the file shown in single-file mode, in place of the
+// rows of a directory
+func (m Model) filePanel() string {
+	file := m.RootDir
+	labelStyle := m.theme().Muted
+	valueStyle := m.theme().File
+
+	rows := [][2]string{
+		{"size", fmt.Sprintf("%s (%s bytes)", humanize.Bytes(uint64(file.Size)), m.formatCount(file.Apparent))},
+		{"modified", file.ModTime.Format("2006-01-02 15:04")},
+		{"permissions", file.Mode.String()},
+	}
+	if file.Owner != "" {
+		rows = append(rows, [2]string{"owner", file.Owner})
+	}
+
+	var s strings.Builder
+	for _, row := range rows {
+		s.WriteString(labelStyle.Render(fmt.Sprintf("  %-12s ", row[0])) + valueStyle.Render(row[1]) + "\n")
+	}
+
+	action := "open it with the default application"
+	if file.Mode&0111 != 0 {
+		action = "execute it"
+	}
+	s.WriteString("\n" + labelStyle.Render("  enter to "+action+", backspace for its directory") + "\n")
+	return s.String()
+}
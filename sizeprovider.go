@@ -0,0 +1,29 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbletea"
+
+	"usage/scan"
+)
+
+// toggleApparentSize switches between apparent sizes and the disk blocks in
+// use. Every cached total was measured the other way, so the cache is
+// dropped and the current directory scanned again.
+func (m *Model) toggleApparentSize() tea.Cmd {
+	if scanner.SizeProvider() == scan.SizeProvider(scan.ApparentSize{}) {
+		scanner.SetSizeProvider(scan.AllocatedSize{})
+		m.Status = "Sizes: disk usage (allocated blocks)"
+	} else {
+		scanner.SetSizeProvider(scan.ApparentSize{})
+		m.Status = "Sizes: apparent (file length)"
+	}
+	m.cancelScan()
+	scanner.ClearCache()
+	if m.RootDir == nil {
+		return nil
+	}
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
+	}
+}
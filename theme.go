@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"sync/atomic"
+
+	"github.com/charmbracelet/lipgloss"
+
+	"usage/scan"
+)
+
+// Theme holds the styles the view is drawn with, built once per Palette
+// instead of on every frame
+type Theme struct {
+	palette  Palette
+	Header   lipgloss.Style
+	Selected lipgloss.Style
+	Dir      lipgloss.Style
+	File     lipgloss.Style
+	Size     lipgloss.Style
+	Percent  lipgloss.Style
+	Muted    lipgloss.Style
+	Error    lipgloss.Style
+	// Flash marks rows that changed in the last re-scan
+	Flash lipgloss.Style
+	// Hot and Warm replace the file and size styles of large entries, with
+	// HotDir and WarmDir for directory names
+	Hot     lipgloss.Style
+	Warm    lipgloss.Style
+	HotDir  lipgloss.Style
+	WarmDir lipgloss.Style
+	// selectOn starts the selected row's background, see selectRow
+	selectOn string
+	// Prompt is the question or input line at the bottom
+	Prompt lipgloss.Style
+	// BarOwn and BarNested draw the usage bar segments
+	BarOwn    lipgloss.Style
+	BarNested lipgloss.Style
+	// Preview frames the preview pane, whose notes are in Note
+	Preview lipgloss.Style
+	Note    lipgloss.Style
+}
+
+// currentTheme is the Theme built last. A session uses a single palette, so
+// after the first frame it is reused without building or allocating anything.
+var currentTheme atomic.Pointer[Theme]
+
+// newTheme builds the styles for p. Without a background color for the
+// header, the selected row or changed rows, they are set apart by reverse
+// video and underlining instead.
+func newTheme(p Palette) *Theme {
+	t := &Theme{
+		palette:   p,
+		Header:    lipgloss.NewStyle().Foreground(p.HeaderFg).Background(p.HeaderBg).AlignHorizontal(lipgloss.Right),
+		Selected:  lipgloss.NewStyle().Background(p.Selected),
+		Dir:       lipgloss.NewStyle().Foreground(p.Dir).Bold(true),
+		File:      lipgloss.NewStyle().Foreground(p.File),
+		Size:      lipgloss.NewStyle().Foreground(p.Size),
+		Percent:   lipgloss.NewStyle().Foreground(p.Percent),
+		Muted:     lipgloss.NewStyle().Foreground(p.Muted),
+		Error:     lipgloss.NewStyle().Foreground(p.Error),
+		Flash:     lipgloss.NewStyle().Background(p.Changed),
+		Prompt:    lipgloss.NewStyle().Foreground(p.HeaderFg).Bold(true),
+		BarOwn:    lipgloss.NewStyle().Foreground(p.BarOwn),
+		BarNested: lipgloss.NewStyle().Foreground(p.BarNested),
+		Preview: lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderLeft(true).
+			BorderForeground(p.Selected).
+			PaddingLeft(1),
+		Note:    lipgloss.NewStyle().Foreground(p.Muted).Italic(true),
+		Hot:     lipgloss.NewStyle().Foreground(p.Hot),
+		Warm:    lipgloss.NewStyle().Foreground(p.Warm),
+		HotDir:  lipgloss.NewStyle().Foreground(p.Hot).Bold(true),
+		WarmDir: lipgloss.NewStyle().Foreground(p.Warm).Bold(true),
+	}
+	if p.HeaderBg == "" {
+		t.Header = t.Header.Reverse(true)
+	}
+	if p.Selected == "" {
+		t.Selected = t.Selected.Reverse(true)
+	}
+	if p.Changed == "" {
+		t.Flash = t.Flash.Underline(true)
+	}
+	t.selectOn, _, _ = strings.Cut(t.Selected.Render("\x00"), "\x00")
+	return t
+}
+
+// Entries above these shares of their directory's total are drawn in the
+// Hot and Warm colors
+const (
+	hotPercent  = 50.0
+	warmPercent = 20.0
+)
+
+// entryStyles returns the styles for the name and size of entry, by its
+// share of its directory
+func (t *Theme) entryStyles(entry *scan.DirEntry) (name, size lipgloss.Style) {
+	switch {
+	case entry.Percent > hotPercent && entry.IsDir:
+		return t.HotDir, t.Hot
+	case entry.Percent > hotPercent:
+		return t.Hot, t.Hot
+	case entry.Percent > warmPercent && entry.IsDir:
+		return t.WarmDir, t.Warm
+	case entry.Percent > warmPercent:
+		return t.Warm, t.Warm
+	case entry.IsDir:
+		return t.Dir, t.Size
+	}
+	return t.File, t.Size
+}
+
+// selectRow draws line with the selected background. The styles inside the
+// line end in a reset, after which the background is started again, so it
+// runs the full width under the colored name, size and percent.
+func (t *Theme) selectRow(line string) string {
+	if t.selectOn != "" {
+		line = strings.ReplaceAll(line, ansiReset, ansiReset+t.selectOn)
+	}
+	return t.Selected.Render(line)
+}
+
+// ansiReset ends every style lipgloss renders
+const ansiReset = "\x1b[0m"
+
+// theme returns the styles for the model's palette
+func (m Model) theme() *Theme {
+	if t := currentTheme.Load(); t != nil && t.palette == m.Palette {
+		return t
+	}
+	t := newTheme(m.Palette)
+	currentTheme.Store(t)
+	return t
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// BenchmarkView renders a few hundred entries without reusing the frame, in
+// colors, so every call goes through the styles. "cached theme" is how the
+// view runs, "new theme" builds the styles again on every call as View did
+// before they were cached.
+func BenchmarkView(b *testing.B) {
+	lipgloss.SetColorProfile(termenv.TrueColor)
+	defer lipgloss.SetColorProfile(termenv.Ascii)
+
+	m := newTestModel(200, 200)
+	m.frame = nil
+	m.CursorPos = 5
+
+	b.Run("cached theme", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			_ = m.View()
+		}
+	})
+	b.Run("new theme", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			currentTheme.Store(nil)
+			_ = m.View()
+		}
+	})
+}
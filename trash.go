@@ -0,0 +1,133 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// trashedEntry is an entry moved to the trash in this session
+type trashedEntry struct {
+	// Path is where the entry was, and where undo puts it back
+	Path string
+	// TrashPath is where it is in the trash
+	TrashPath string
+}
+
+// RestoreMsg is sent when an entry has been moved back out of the trash
+type RestoreMsg struct {
+	Entry trashedEntry
+	Error error
+}
+
+// removeCmd deletes paths, or moves them to the trash in trash mode
+func (m Model) removeCmd(paths []string) tea.Cmd {
+	if m.Trash {
+		return trashCmd(paths)
+	}
+	return deleteCmd(paths)
+}
+
+// trashCmd moves paths to the trash, and stops at the first failure
+func trashCmd(paths []string) tea.Cmd {
+	return func() tea.Msg {
+		var trashed []trashedEntry
+		for i, path := range paths {
+			entry, err := moveToTrash(path)
+			if err != nil {
+				return DeleteMsg{paths[:i], trashed, err}
+			}
+			trashed = append(trashed, entry)
+		}
+		return DeleteMsg{paths, trashed, nil}
+	}
+}
+
+// moveToTrash moves path into the trash under its own name, numbered if the
+// trash has one by that name already. The entry is renamed rather than
+// copied, so trashFiles picks a trash on its filesystem.
+func moveToTrash(path string) (trashedEntry, error) {
+	files, err := trashFiles(path)
+	if err != nil {
+		return trashedEntry{}, err
+	}
+	if err := os.MkdirAll(files, 0o700); err != nil {
+		return trashedEntry{}, err
+	}
+
+	base := filepath.Base(path)
+	for n := 1; ; n++ {
+		name := base
+		if n > 1 {
+			name += "." + strconv.Itoa(n)
+		}
+		trashPath := filepath.Join(files, name)
+		if _, err := os.Lstat(trashPath); err == nil {
+			continue
+		}
+		if err := writeTrashInfo(files, name, path); errors.Is(err, fs.ErrExist) {
+			continue
+		} else if err != nil {
+			return trashedEntry{}, err
+		}
+		if err := os.Rename(path, trashPath); err != nil {
+			removeTrashInfo(trashPath)
+			return trashedEntry{}, fmt.Errorf("cannot move %s to the trash: %w", path, err)
+		}
+		return trashedEntry{Path: path, TrashPath: trashPath}, nil
+	}
+}
+
+// restoreFromTrash moves entry back to where it was, unless something has
+// taken its place since
+func restoreFromTrash(entry trashedEntry) error {
+	if _, err := os.Lstat(entry.Path); err == nil {
+		return fmt.Errorf("%s exists again", entry.Path)
+	}
+	if err := os.Rename(entry.TrashPath, entry.Path); err != nil {
+		return err
+	}
+	removeTrashInfo(entry.TrashPath)
+	return nil
+}
+
+// undoTrash restores the entry moved to the trash last, so repeated presses
+// restore the earlier ones in turn
+func (m *Model) undoTrash() tea.Cmd {
+	if !m.Trash {
+		m.Status = "Undo only works in trash mode (trash in the config file)"
+		return nil
+	}
+	if len(m.trashed) == 0 {
+		m.Status = "Nothing to undo"
+		return nil
+	}
+	entry := m.trashed[len(m.trashed)-1]
+	m.trashed = m.trashed[:len(m.trashed)-1]
+	m.Status = fmt.Sprintf("Restoring %s...", entry.Path)
+	return func() tea.Msg {
+		return RestoreMsg{entry, restoreFromTrash(entry)}
+	}
+}
+
+// applyRestore re-scans the current directory once an entry is back from
+// the trash. An entry that couldn't be restored stays next in line for undo.
+func (m *Model) applyRestore(msg RestoreMsg) tea.Cmd {
+	if msg.Error != nil {
+		m.trashed = append(m.trashed, msg.Entry)
+		m.Status = fmt.Sprintf("Restore failed: %v", msg.Error)
+		return nil
+	}
+
+	m.Status = fmt.Sprintf("Restored %s", msg.Entry.Path)
+	scanner.Invalidate(msg.Entry.Path)
+	path := m.RootDir.Path
+	return func() tea.Msg {
+		return LoadingMsg{Path: path, Refresh: true}
+	}
+}
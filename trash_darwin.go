@@ -0,0 +1,38 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// trashFiles returns the directory path is moved into: the user's trash in
+// Finder, or for entries on another volume, which can't be renamed into it,
+// the volume's own .Trashes/$uid that Finder shows along with it
+func trashFiles(path string) (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	trash := filepath.Join(home, ".Trash")
+	dev, err := deviceOf(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	if onDevice(trash, dev) {
+		return trash, nil
+	}
+	top, err := volumeTop(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(top, ".Trashes", strconv.Itoa(os.Getuid())), nil
+}
+
+// writeTrashInfo has nothing to record on macOS
+func writeTrashInfo(files, name, path string) error {
+	return nil
+}
+
+// removeTrashInfo has nothing to remove on macOS
+func removeTrashInfo(trashPath string) {}
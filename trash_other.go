@@ -0,0 +1,87 @@
+//go:build !darwin && !windows
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// homeTrash returns the home trash of the freedesktop.org trash
+// specification, which file managers on Linux and the BSDs share
+func homeTrash() (string, error) {
+	if data := os.Getenv("XDG_DATA_HOME"); data != "" {
+		return filepath.Join(data, "Trash"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".local", "share", "Trash"), nil
+}
+
+// trashDir returns the trash path is moved to: the home trash, or for
+// entries on another filesystem, which can't be renamed into it, the
+// $topdir/.Trash-$uid trash of their own filesystem
+func trashDir(path string) (string, error) {
+	home, err := homeTrash()
+	if err != nil {
+		return "", err
+	}
+	dev, err := deviceOf(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	if onDevice(home, dev) {
+		return home, nil
+	}
+	top, err := volumeTop(filepath.Dir(path))
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(top, ".Trash-"+strconv.Itoa(os.Getuid())), nil
+}
+
+// trashFiles returns the directory path is moved into
+func trashFiles(path string) (string, error) {
+	dir, err := trashDir(path)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "files"), nil
+}
+
+// trashInfo returns the record of the entry at trashPath in a trash's files
+func trashInfo(trashPath string) string {
+	dir := filepath.Dir(filepath.Dir(trashPath))
+	return filepath.Join(dir, "info", filepath.Base(trashPath)+".trashinfo")
+}
+
+// writeTrashInfo records where the entry trashed as name in files came
+// from, so file managers can restore it too. It fails with fs.ErrExist when
+// name is taken.
+func writeTrashInfo(files, name, path string) error {
+	info := trashInfo(filepath.Join(files, name))
+	if err := os.MkdirAll(filepath.Dir(info), 0o700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(info, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: path}).EscapedPath(), time.Now().Format("2006-01-02T15:04:05"))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// removeTrashInfo drops the record of the entry at trashPath
+func removeTrashInfo(trashPath string) {
+	os.Remove(trashInfo(trashPath))
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestUndoRestoresTrashedEntriesInTurn(t *testing.T) {
+	root := t.TempDir()
+	// The trash is found through these on Linux and macOS respectively
+	t.Setenv("XDG_DATA_HOME", filepath.Join(root, "data"))
+	t.Setenv("HOME", root)
+
+	// Two entries of the same name must not clash in the trash
+	first := filepath.Join(root, "a", "cache")
+	second := filepath.Join(root, "b", "cache")
+	for _, path := range []string{first, second} {
+		if err := os.MkdirAll(path, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(path, "blob"), []byte(path), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := newTestModel(2, 2)
+	m.Trash = true
+	for _, path := range []string{first, second} {
+		msg := trashCmd([]string{path})().(DeleteMsg)
+		if msg.Error != nil {
+			t.Fatal(msg.Error)
+		}
+		m.applyDelete(msg)
+		if _, err := os.Lstat(path); err == nil {
+			t.Fatalf("%s is still there after moving it to the trash", path)
+		}
+	}
+
+	for _, path := range []string{second, first} {
+		cmd := m.undoTrash()
+		if cmd == nil {
+			t.Fatalf("nothing to undo before restoring %s: %s", path, m.Status)
+		}
+		m.applyRestore(cmd().(RestoreMsg))
+		data, err := os.ReadFile(filepath.Join(path, "blob"))
+		if err != nil || string(data) != path {
+			t.Errorf("%s not restored: %v (%s)", path, err, m.Status)
+		}
+	}
+	if m.undoTrash() != nil || m.Status != "Nothing to undo" {
+		t.Errorf("undo with an empty stack: %s", m.Status)
+	}
+}
+
+func TestUndoNeedsTrashMode(t *testing.T) {
+	m := newTestModel(2, 2)
+	m.trashed = []trashedEntry{{Path: "/data/dir/dir000", TrashPath: "/trash/dir000"}}
+	tm, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("u")})
+	if cmd != nil || len(tm.(Model).trashed) != 1 {
+		t.Error("u restored an entry without trash mode")
+	}
+}
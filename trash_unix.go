@@ -0,0 +1,57 @@
+//go:build unix
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// deviceOf returns the device of the filesystem holding path
+func deviceOf(path string) (uint64, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return 0, fmt.Errorf("no device number for %s", path)
+	}
+	return uint64(stat.Dev), nil
+}
+
+// onDevice reports whether dir, or its closest existing ancestor when it
+// hasn't been created yet, is on device dev
+func onDevice(dir string, dev uint64) bool {
+	for {
+		if d, err := deviceOf(dir); err == nil {
+			return d == dev
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return false
+		}
+		dir = parent
+	}
+}
+
+// volumeTop returns the mount point of the filesystem holding dir, the
+// topmost directory above it on the same device
+func volumeTop(dir string) (string, error) {
+	dev, err := deviceOf(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return dir, nil
+		}
+		if d, err := deviceOf(parent); err != nil || d != dev {
+			return dir, nil
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestTrashOnAnotherFilesystem(t *testing.T) {
+	// /dev/shm is a tmpfs of its own on Linux
+	dir, err := os.MkdirTemp("/dev/shm", "usage-trash")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer os.RemoveAll(dir)
+	home := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", home)
+	shm, err := deviceOf(dir)
+	if err != nil || onDevice(home, shm) || onDevice("/dev", shm) {
+		t.Skip("/dev/shm is not a filesystem of its own")
+	}
+
+	files, err := trashFiles(filepath.Join(dir, "cache"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := filepath.Join("/dev/shm", ".Trash-"+strconv.Itoa(os.Getuid()), "files")
+	if files != want {
+		t.Errorf("trash for an entry in %s is %s, want %s", dir, files, want)
+	}
+	files, err = trashFiles(filepath.Join(home, "cache"))
+	if err != nil || files != filepath.Join(home, "Trash", "files") {
+		t.Errorf("trash for an entry next to the home trash is %s (%v)", files, err)
+	}
+}
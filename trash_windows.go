@@ -0,0 +1,20 @@
+package main
+
+import "errors"
+
+// errTrashUnsupported is returned for every entry in trash mode on Windows,
+// where moving to the Recycle Bin would take the shell API
+var errTrashUnsupported = errors.New("trash mode is not supported on Windows, turn off trash in the config file to delete")
+
+// trashFiles fails, as there is no trash to move entries into on Windows
+func trashFiles(path string) (string, error) {
+	return "", errTrashUnsupported
+}
+
+// writeTrashInfo has nothing to record on Windows
+func writeTrashInfo(files, name, path string) error {
+	return nil
+}
+
+// removeTrashInfo has nothing to remove on Windows
+func removeTrashInfo(trashPath string) {}